@@ -0,0 +1,172 @@
+package experiments
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+	redis "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+// maxBatchSize is the most tasks one batch may hold; a full batch uses the
+// whole per-minute rate limit
+const maxBatchSize = 100
+
+func Batch(router *gin.Engine) {
+	// submit up to maxBatchSize tasks to one queue in a single request
+	router.POST("/tasks/batch", postTaskBatch)
+}
+
+// postTaskBatch creates every valid task of the batch in one pipelined round
+// trip and reports per task whether it was created, duplicates an existing
+// task or is invalid. The rate limit is charged per task.
+func postTaskBatch(c *gin.Context) {
+	var req models.BatchTaskRequest
+
+	// Bind and validate JSON request
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(req.Tasks) == 0 || len(req.Tasks) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("tasks must hold between 1 and %d tasks", maxBatchSize),
+		})
+		return
+	}
+
+	// rate limit, one request per task
+	if !allowRequestN(c, len(req.Tasks)) {
+		return
+	}
+
+	queue := req.Queue
+	if queue == "" {
+		queue = redis.FIFO_QUEUE_NAME
+	}
+	if !redis.ValidQueueName(queue) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "queue must be 1-64 letters, digits, '-' or '_'",
+		})
+		return
+	}
+
+	// Validate every task on its own; invalid tasks don't stop the others
+	results := make([]gin.H, len(req.Tasks))
+	var tasks []*models.Task
+	var records []*redis.IdempotencyRecord
+	var indexes []int
+	submittedAt := time.Now()
+	for i := range req.Tasks {
+		item := &req.Tasks[i]
+		task, err := buildTask(item, queue, submittedAt)
+		if err != nil {
+			results[i] = gin.H{"index": i, "status": "invalid", "error": err.Error()}
+			continue
+		}
+
+		// Same record a single submission with this ID would leave, so that
+		// reusing the ID either way replays or conflicts
+		var record *redis.IdempotencyRecord
+		if item.ID != "" {
+			record, err = newIdempotencyRecord(item, queue, http.StatusCreated, gin.H{
+				"message": fmt.Sprintf("Task created successfully (queue %s)", queue),
+				"task":    task,
+			})
+			if err != nil {
+				results[i] = gin.H{"index": i, "status": "error", "error": "Failed to create task"}
+				continue
+			}
+		}
+		tasks = append(tasks, task)
+		records = append(records, record)
+		indexes = append(indexes, i)
+	}
+
+	// Store and enqueue the valid tasks, each atomically, in one pipeline
+	existingIDs, errs, err := redis.CreateTasksInQueue(tasks, records)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create tasks",
+		})
+		return
+	}
+
+	// Look up the tasks the duplicates point at in one round trip
+	var duplicateIDs []string
+	for k := range tasks {
+		if errs[k] == nil && existingIDs[k] != "" {
+			duplicateIDs = append(duplicateIDs, existingIDs[k])
+		}
+	}
+	existingTasks, err := redis.GetTasks(duplicateIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve existing tasks",
+		})
+		return
+	}
+	existingByID := make(map[string]*models.Task, len(existingTasks))
+	for _, t := range existingTasks {
+		existingByID[t.ID] = t
+	}
+
+	for k, task := range tasks {
+		i := indexes[k]
+		switch {
+		case errs[k] != nil:
+			results[i] = gin.H{"index": i, "status": "error", "id": task.ID, "error": "Failed to create task"}
+		case existingIDs[k] == "":
+			results[i] = gin.H{"index": i, "status": "created", "id": task.ID, "task": task}
+		default:
+			results[i] = batchDuplicate(i, task.ID, existingIDs[k], records[k], existingByID[existingIDs[k]])
+		}
+	}
+
+	counts := map[string]int{"created": 0, "duplicate": 0, "invalid": 0, "error": 0}
+	for _, result := range results {
+		counts[result["status"].(string)]++
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"queue":   queue,
+		"counts":  counts,
+		"results": results,
+	})
+}
+
+// batchDuplicate reports a task of a batch that already exists. A reused ID
+// whose original request differs is invalid, as it would be a 409 on its own.
+func batchDuplicate(index int, taskID, existingID string, record *redis.IdempotencyRecord, existing *models.Task) gin.H {
+	if existingID == taskID && record != nil {
+		original, err := redis.GetIdempotencyRecord(taskID)
+		if err != nil && err != redis.Nil {
+			return gin.H{"index": index, "status": "error", "id": taskID, "error": "Failed to retrieve idempotency record"}
+		}
+		if err == nil && original.Fingerprint != record.Fingerprint {
+			return gin.H{
+				"index":       index,
+				"status":      "invalid",
+				"id":          taskID,
+				"error":       "Task ID already used with a different request",
+				"differences": diffRequests(original.Request, record.Request),
+			}
+		}
+		// The record outlives tasks with a shorter retention; report the
+		// task as originally created then
+		if err == nil && existing == nil {
+			var response struct {
+				Task *models.Task `json:"task"`
+			}
+			if json.Unmarshal(original.Response, &response) == nil {
+				existing = response.Task
+			}
+		}
+	}
+	return gin.H{"index": index, "status": "duplicate", "id": existingID, "task": existing}
+}
@@ -0,0 +1,149 @@
+package experiments
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	redis "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+// maximum number of dead-letter entries returned per page
+const maxDeadLetterPageSize = 500
+
+func DeadLetterQueue(router *gin.Engine) {
+	// inspect permanently failed tasks
+	router.GET("/dlq", getDeadLetters)
+	// requeue one or all dead-lettered tasks with a fresh retry budget
+	router.POST("/dlq/requeue", requeueAllDeadLetters)
+	router.POST("/dlq/:id/requeue", requeueDeadLetter)
+	// drop one or all tasks from the dead-letter queue
+	router.DELETE("/dlq", purgeAllDeadLetters)
+	router.DELETE("/dlq/:id", purgeDeadLetter)
+}
+
+// getDeadLetters returns a page of dead-letter entries, oldest failure first
+// Query params: offset (default 0), limit (default 50, max 500)
+func getDeadLetters(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset must be a non-negative integer",
+		})
+		return
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil || limit <= 0 || limit > maxDeadLetterPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be between 1 and 500",
+		})
+		return
+	}
+
+	total, err := redis.GetDeadLetterCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count dead-letter queue",
+		})
+		return
+	}
+
+	entries, err := redis.ListDeadLetters(offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list dead-letter queue",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"offset":  offset,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+// requeueDeadLetter puts one dead-lettered task back into its queue
+func requeueDeadLetter(c *gin.Context) {
+	taskID := c.Param("id")
+
+	requeued, err := redis.RequeueDeadLetter(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to requeue task",
+		})
+		return
+	}
+	if !requeued {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Task not found in dead-letter queue",
+			"id":    taskID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task requeued",
+		"id":      taskID,
+	})
+}
+
+// requeueAllDeadLetters puts every dead-lettered task back into its queue
+func requeueAllDeadLetters(c *gin.Context) {
+	requeued, err := redis.RequeueAllDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":    "Failed to requeue dead-letter queue",
+			"requeued": requeued,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Dead-letter queue requeued",
+		"requeued": requeued,
+	})
+}
+
+// purgeDeadLetter drops one task from the dead-letter queue
+func purgeDeadLetter(c *gin.Context) {
+	taskID := c.Param("id")
+
+	purged, err := redis.PurgeDeadLetter(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to purge task",
+		})
+		return
+	}
+	if !purged {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Task not found in dead-letter queue",
+			"id":    taskID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task purged from dead-letter queue",
+		"id":      taskID,
+	})
+}
+
+// purgeAllDeadLetters empties the dead-letter queue
+func purgeAllDeadLetters(c *gin.Context) {
+	purged, err := redis.PurgeAllDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to purge dead-letter queue",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Dead-letter queue purged",
+		"purged":  purged,
+	})
+}
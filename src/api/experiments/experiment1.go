@@ -1,6 +1,7 @@
 package experiments
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -8,7 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	models "github.com/yourusername/distributed-task-queue/src/api/models"
-	rl "github.com/yourusername/distributed-task-queue/src/api/ratelimit"
+	"github.com/yourusername/distributed-task-queue/src/jobs"
 	redis "github.com/yourusername/distributed-task-queue/src/redis"
 )
 
@@ -19,33 +20,24 @@ func Experiment1(router *gin.Engine) {
 	router.POST("/task/fifo", postTaskFIFO)
 	// associate POST HTTP method and "/task/pq" path with a handler function "postTaskPQ"
 	router.POST("/task/pq", postTaskPQ)
+	// associate DELETE HTTP method and "/task/:id" path with a handler function "cancelTask"
+	router.DELETE("/task/:id", cancelTask)
 }
 
 // postTaskFIFO handles task submission from client to FIFO Queue
 func postTaskFIFO(c *gin.Context) {
+	submitTask(c, redis.FIFO_QUEUE_NAME)
+}
 
-	// rate limit
-	clientID := c.ClientIP()
-	rateLimitResult, err := rl.Allow(c.Request.Context(), clientID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "rate limiter error",
-		})
-		return
-	}
+// postTaskPQ handles task submission to priority queue
+func postTaskPQ(c *gin.Context) {
+	submitTask(c, redis.PRIORITY_QUEUE_NAME)
+}
 
-	// Set standard rate limit headers for all responses
-	c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", rateLimitResult.Limit))
-	c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", rateLimitResult.Remaining))
-
-	if !rateLimitResult.Allowed {
-		// Set Retry-After header when rate limited
-		c.Header("Retry-After", fmt.Sprintf("%d", rateLimitResult.RetryAfter))
-		c.JSON(http.StatusTooManyRequests, gin.H{
-			"error":       "rate limit exceeded",
-			"remaining":   rateLimitResult.Remaining,
-			"retry_after": rateLimitResult.RetryAfter,
-		})
+// submitTask validates a task submission, then stores and enqueues the task
+// to queue. It backs /task/fifo, /task/pq and /queues/:name/tasks.
+func submitTask(c *gin.Context, queue string) {
+	if !allowRequest(c) {
 		return
 	}
 
@@ -60,33 +52,54 @@ func postTaskFIFO(c *gin.Context) {
 		return
 	}
 
-	// Validate job_type
-	if req.JobType != "short" && req.JobType != "long" {
+	// Validate the request and build the task; a generated ID isn't
+	// idempotent, only client-chosen IDs are
+	task, err := buildTask(&req, queue, time.Now())
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "job_type must be 'short' or 'long'",
+			"error": err.Error(),
 		})
 		return
 	}
+	taskID := task.ID
+	hasID := req.ID != ""
 
-	// Generate task ID if not provided (for idempotency)
-	if req.ID == "" {
-		req.ID = uuid.New().String()
+	response := gin.H{
+		"message": fmt.Sprintf("Task created successfully (queue %s)", queue),
+		"task":    task,
 	}
 
-	taskID := req.ID
+	// Remember the request and response, so that reusing the ID replays the
+	// response or is rejected if the request differs
+	var record *redis.IdempotencyRecord
+	if hasID {
+		record, err = newIdempotencyRecord(&req, queue, http.StatusCreated, response)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to create task",
+			})
+			return
+		}
+	}
 
-	// Check for duplicate task ID (idempotency)
-	exists, err := redis.TaskExists(taskID)
+	// Store and enqueue (or schedule) task to its queue in one atomic step,
+	// unless a task with this ID already exists. The priority queue orders by
+	// priority, then submission order; every other queue is FIFO.
+	existingID, err := redis.CreateTaskInQueue(task, record)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to check task existence",
+			"error": "Failed to create task",
 		})
 		return
 	}
 
-	if exists {
-		// Task already exists, return existing task info
-		existingTask, err := redis.GetTask(taskID)
+	if existingID == taskID && record != nil && replayIdempotent(c, taskID, record) {
+		return
+	}
+	if existingID != "" {
+		// Task already exists (unique key, or created without an idempotency
+		// record), return existing task info
+		existingTask, err := redis.GetTask(existingID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Failed to retrieve existing task",
@@ -100,151 +113,103 @@ func postTaskFIFO(c *gin.Context) {
 		return
 	}
 
-	// Create new task
-	task := models.Task{
-		ID:          taskID,
-		JobType:     req.JobType,
-		Payload:     req.Payload,
-		Status:      "queued",
-		SubmittedAt: time.Now(),
-		RetryCount:  0,
-	}
-
-	// Store task in Redis
-	err = redis.StoreTask(&task)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to store task",
-		})
-		return
-	}
-
-	// Enqueue task to FIFO queue
-	err = redis.EnqueueFIFO(task.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to enqueue task",
-		})
-		return
-	}
-
 	// Return success response
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Task created successfully (FIFO queue)",
-		"task":    task,
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
-// postTaskPQ handles task submission to priority queue
-func postTaskPQ(c *gin.Context) {
-	clientID := c.ClientIP()
-	rateLimitResult, err := rl.Allow(c.Request.Context(), clientID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "rate limiter error",
-		})
-		return
+// buildTask validates a task submission and builds the task for queue. It is
+// shared by single submissions and every task of a batch, so both apply the
+// same rules.
+func buildTask(req *models.TaskRequest, queue string, submittedAt time.Time) (*models.Task, error) {
+	if err := jobs.CheckJobType(req.JobType); err != nil {
+		return nil, err
 	}
 
-	// Set standard rate limit headers for all responses
-	c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", rateLimitResult.Limit))
-	c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", rateLimitResult.Remaining))
-
-	if !rateLimitResult.Allowed {
-		// Set Retry-After header when rate limited
-		c.Header("Retry-After", fmt.Sprintf("%d", rateLimitResult.RetryAfter))
-		c.JSON(http.StatusTooManyRequests, gin.H{
-			"error":       "rate limit exceeded",
-			"remaining":   rateLimitResult.Remaining,
-			"retry_after": rateLimitResult.RetryAfter,
-		})
-		return
+	// Validate priority, defaulting by job type (short jobs first)
+	priority := redis.DefaultPriority(req.JobType)
+	if req.Priority != nil {
+		if *req.Priority < 0 || *req.Priority > redis.MAX_PRIORITY {
+			return nil, fmt.Errorf("priority must be between 0 and %d", redis.MAX_PRIORITY)
+		}
+		priority = *req.Priority
 	}
 
-	var req models.TaskRequest
-
-	// Bind and validate JSON request
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
-		return
+	if req.ResultTTL < 0 {
+		return nil, errors.New("result_ttl must not be negative")
 	}
-
-	// Validate job_type
-	if req.JobType != "short" && req.JobType != "long" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "job_type must be 'short' or 'long'",
-		})
-		return
+	if req.Timeout < 0 {
+		return nil, errors.New("timeout must not be negative")
 	}
 
-	// Generate task ID if not provided
-	if req.ID == "" {
-		req.ID = uuid.New().String()
+	// Validate uniqueness; without unique_key, job_type and payload are hashed
+	if req.UniqueFor < 0 {
+		return nil, errors.New("unique_for must not be negative")
+	}
+	if req.UniqueKey != "" && req.UniqueFor == 0 {
+		return nil, errors.New("unique_key requires unique_for")
+	}
+	uniqueKey := ""
+	if req.UniqueFor > 0 {
+		uniqueKey = redis.UniqueKey(req.JobType, req.Payload, req.UniqueKey)
 	}
 
-	taskID := req.ID
-
-	// Check for duplicate task ID (idempotency)
-	exists, err := redis.TaskExists(taskID)
+	// Work out when a delayed task should run (nil runs it right away)
+	runAt, err := scheduledTime(req, submittedAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to check task existence",
-		})
-		return
+		return nil, err
+	}
+	status := "queued"
+	if runAt != nil {
+		status = "scheduled"
 	}
 
-	if exists {
-		// Task already exists, return existing task info
-		existingTask, err := redis.GetTask(taskID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to retrieve existing task",
-			})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Task already exists",
-			"task":    existingTask,
-		})
-		return
+	taskID := req.ID
+	if taskID == "" {
+		taskID = uuid.New().String()
 	}
 
-	// Create new task
-	task := models.Task{
+	return &models.Task{
 		ID:          taskID,
 		JobType:     req.JobType,
 		Payload:     req.Payload,
-		Status:      "queued",
-		SubmittedAt: time.Now(),
+		Status:      status,
+		Priority:    priority,
+		Queue:       queue,
+		SubmittedAt: submittedAt,
+		ScheduledAt: runAt,
 		RetryCount:  0,
-	}
+		ResultTTL:   req.ResultTTL,
+		UniqueKey:   uniqueKey,
+		UniqueFor:   req.UniqueFor,
+		Timeout:     req.Timeout,
+	}, nil
+}
 
-	// Store task in Redis
-	err = redis.StoreTask(&task)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to store task",
-		})
-		return
+// scheduledTime returns when a delayed task should run from run_at or
+// delay_seconds, or nil if it should run right away
+func scheduledTime(req *models.TaskRequest, now time.Time) (*time.Time, error) {
+	if req.RunAt != nil && req.DelaySeconds != 0 {
+		return nil, errors.New("run_at and delay_seconds can't be used together")
+	}
+	if req.DelaySeconds < 0 {
+		return nil, errors.New("delay_seconds must not be negative")
 	}
 
-	// Enqueue task to PRIORITY queue (short jobs get higher priority)
-	err = redis.EnqueuePriority(task.ID, task.JobType)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to enqueue task",
-		})
-		return
+	var runAt time.Time
+	switch {
+	case req.RunAt != nil:
+		runAt = *req.RunAt
+	case req.DelaySeconds > 0:
+		runAt = now.Add(time.Duration(req.DelaySeconds) * time.Second)
+	default:
+		return nil, nil
 	}
 
-	// Return success response
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Task created successfully (Priority queue)",
-		"task":    task,
-	})
+	// A run time in the past is already due
+	if !runAt.After(now) {
+		return nil, nil
+	}
+	return &runAt, nil
 }
 
 // getTaskByByID locates the task whose ID value matches the id
@@ -263,3 +228,74 @@ func getTaskByID(c *gin.Context) {
 
 	c.JSON(http.StatusOK, task)
 }
+
+// cancelTask cancels a task that has not finished yet. Waiting tasks are
+// removed from their queue and marked "cancelled" right away; running tasks
+// get their worker signalled and are marked "cancelled" once it stops them.
+func cancelTask(c *gin.Context) {
+	taskID := c.Param("id")
+
+	outcome, err := redis.CancelTask(taskID)
+	if err == redis.Nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Task not found",
+			"id":    taskID,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel task",
+		})
+		return
+	}
+
+	switch outcome {
+	case redis.CancelSignalled:
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Cancellation requested, the worker will stop the task",
+			"id":      taskID,
+		})
+
+	case redis.CancelRemoved:
+		task, err := redis.GetTask(taskID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to retrieve task",
+			})
+			return
+		}
+		completed := time.Now()
+		task.Status = "cancelled"
+		task.CompletedAt = &completed
+		task.Error = "cancelled by user"
+		if err := redis.StoreTask(task); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to store task",
+			})
+			return
+		}
+		// Workflow tasks waiting on this one will never run
+		if task.WorkflowID != "" {
+			reason := fmt.Sprintf("dependency %s was cancelled", task.ID)
+			if _, err := redis.SkipDependents(task.ID, reason); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to skip dependent tasks",
+				})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Task cancelled",
+			"task":    task,
+		})
+
+	default:
+		// The task already finished, outcome is its final status
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Task already finished",
+			"id":     taskID,
+			"status": outcome,
+		})
+	}
+}
@@ -2,6 +2,7 @@ package experiments
 
 import (
 	"net/http"
+	"sort"
 
 	"github.com/gin-gonic/gin"
 	redis "github.com/yourusername/distributed-task-queue/src/redis"
@@ -14,7 +15,9 @@ func Experiment2(router *gin.Engine) {
 	router.GET("/queue/status", getQueueStatus)
 }
 
-// getQueueStatus returns the current queue lengths for monitoring backlog
+// getQueueStatus returns the current queue lengths, the oldest waiting task
+// per job type, the paused queues and the number of live workers for
+// monitoring backlog, starvation and scaling
 func getQueueStatus(c *gin.Context) {
 	fifoLength, err := redis.GetFIFOQueueLength()
 	if err != nil {
@@ -32,9 +35,41 @@ func getQueueStatus(c *gin.Context) {
 		return
 	}
 
+	oldestWaiting, err := redis.OldestWaitingByJobType()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get oldest waiting tasks",
+		})
+		return
+	}
+
+	paused, err := redis.PausedQueues()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get paused queues",
+		})
+		return
+	}
+	pausedQueues := make([]string, 0, len(paused))
+	for name := range paused {
+		pausedQueues = append(pausedQueues, name)
+	}
+	sort.Strings(pausedQueues)
+
+	workers, err := redis.ListWorkers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list workers",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"fifo_queue_length":     fifoLength,
 		"priority_queue_length": priorityLength,
 		"total_backlog":         fifoLength + priorityLength,
+		"oldest_waiting":        oldestWaiting,
+		"paused_queues":         pausedQueues,
+		"workers":               len(workers),
 	})
 }
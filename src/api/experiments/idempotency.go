@@ -0,0 +1,123 @@
+package experiments
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+	redis "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+// idempotentRequest is the part of a task submission that a request reusing
+// the task's ID has to repeat
+type idempotentRequest struct {
+	JobType      string     `json:"job_type"`
+	Payload      string     `json:"payload"`
+	Queue        string     `json:"queue"`
+	Priority     *int       `json:"priority"`
+	RunAt        *time.Time `json:"run_at"`
+	DelaySeconds int        `json:"delay_seconds"`
+	ResultTTL    int        `json:"result_ttl"`
+	UniqueKey    string     `json:"unique_key"`
+	UniqueFor    int        `json:"unique_for"`
+	Timeout      int        `json:"timeout"`
+}
+
+// newIdempotencyRecord builds the record a task submitted to queue is
+// remembered by, with the response it is about to get
+func newIdempotencyRecord(req *models.TaskRequest, queue string, statusCode int, response gin.H) (*redis.IdempotencyRecord, error) {
+	canonical := idempotentRequest{
+		JobType:      req.JobType,
+		Payload:      req.Payload,
+		Queue:        queue,
+		Priority:     req.Priority,
+		RunAt:        req.RunAt,
+		DelaySeconds: req.DelaySeconds,
+		ResultTTL:    req.ResultTTL,
+		UniqueKey:    req.UniqueKey,
+		UniqueFor:    req.UniqueFor,
+		Timeout:      req.Timeout,
+	}
+	if canonical.RunAt != nil {
+		// The same instant in another time zone is the same request
+		runAt := canonical.RunAt.UTC()
+		canonical.RunAt = &runAt
+	}
+
+	requestJSON, err := json.Marshal(canonical)
+	if err != nil {
+		return nil, err
+	}
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(requestJSON)
+	return &redis.IdempotencyRecord{
+		Fingerprint: hex.EncodeToString(sum[:]),
+		Request:     requestJSON,
+		StatusCode:  statusCode,
+		Response:    responseJSON,
+	}, nil
+}
+
+// replayIdempotent answers a submission that reused the ID of an existing
+// task: with the original response if the request matches the original one,
+// with 409 Conflict naming the fields that differ otherwise.
+// It returns false without writing anything if the task has no record.
+func replayIdempotent(c *gin.Context, taskID string, record *redis.IdempotencyRecord) bool {
+	original, err := redis.GetIdempotencyRecord(taskID)
+	if err == redis.Nil {
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve idempotency record",
+		})
+		return true
+	}
+
+	if original.Fingerprint == record.Fingerprint {
+		c.Data(original.StatusCode, "application/json; charset=utf-8", original.Response)
+		return true
+	}
+
+	differences := diffRequests(original.Request, record.Request)
+	c.JSON(http.StatusConflict, gin.H{
+		"error":       "Task ID already used with a different request (" + strings.Join(differences, ", ") + " differ)",
+		"id":          taskID,
+		"differences": differences,
+	})
+	return true
+}
+
+// diffRequests returns the names of the fields whose values differ between
+// two idempotentRequest JSON documents, in alphabetical order
+func diffRequests(original, submitted json.RawMessage) []string {
+	var a, b map[string]json.RawMessage
+	if json.Unmarshal(original, &a) != nil || json.Unmarshal(submitted, &b) != nil {
+		return []string{"request"}
+	}
+
+	differences := []string{}
+	for field, value := range b {
+		if !bytes.Equal(a[field], value) {
+			differences = append(differences, field)
+		}
+	}
+	for field := range a {
+		if _, ok := b[field]; !ok {
+			differences = append(differences, field)
+		}
+	}
+	sort.Strings(differences)
+	return differences
+}
@@ -0,0 +1,122 @@
+package experiments
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	redis "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+func Queues(router *gin.Engine) {
+	// list every queue with its backlog
+	router.GET("/queues", getQueues)
+	// submit a task to a named queue, e.g. /queues/critical/tasks
+	router.POST("/queues/:name/tasks", postTaskToQueue)
+	// stop and restart consumption of a queue; submissions are still accepted
+	router.POST("/queues/:name/pause", pauseQueue)
+	router.POST("/queues/:name/resume", resumeQueue)
+}
+
+// getQueues returns the backlog of every queue, built-in queues first
+func getQueues(c *gin.Context) {
+	names, err := redis.ListQueues()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list queues",
+		})
+		return
+	}
+	paused, err := redis.PausedQueues()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get paused queues",
+		})
+		return
+	}
+
+	queues := make([]gin.H, 0, len(names))
+	for _, name := range names {
+		length, err := redis.GetQueueLength(name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to get queue length",
+				"queue": name,
+			})
+			return
+		}
+		queues = append(queues, gin.H{
+			"name":   name,
+			"length": length,
+			"paused": paused[name],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"queues": queues,
+	})
+}
+
+// pauseQueue stops workers from dequeuing from a queue
+func pauseQueue(c *gin.Context) {
+	setQueuePaused(c, true)
+}
+
+// resumeQueue lets workers dequeue from a paused queue again
+func resumeQueue(c *gin.Context) {
+	setQueuePaused(c, false)
+}
+
+// setQueuePaused pauses or resumes the queue named in the path. Pausing a
+// paused queue or resuming a running one is not an error.
+func setQueuePaused(c *gin.Context, pause bool) {
+	queue := c.Param("name")
+	if !redis.ValidQueueName(queue) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "queue name must be 1-64 letters, digits, '-' or '_'",
+		})
+		return
+	}
+
+	var changed bool
+	var err error
+	if pause {
+		changed, err = redis.PauseQueue(queue)
+	} else {
+		changed, err = redis.ResumeQueue(queue)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update queue",
+			"queue": queue,
+		})
+		return
+	}
+
+	state := "resumed"
+	if pause {
+		state = "paused"
+	}
+	message := fmt.Sprintf("Queue %s %s", queue, state)
+	if !changed {
+		message = fmt.Sprintf("Queue %s was already %s", queue, state)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"queue":   queue,
+		"paused":  pause,
+	})
+}
+
+// postTaskToQueue handles task submission to a named queue. Named queues are
+// FIFO; "fifo" and "priority" address the built-in queues.
+func postTaskToQueue(c *gin.Context) {
+	queue := c.Param("name")
+	if !redis.ValidQueueName(queue) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "queue name must be 1-64 letters, digits, '-' or '_'",
+		})
+		return
+	}
+	submitTask(c, queue)
+}
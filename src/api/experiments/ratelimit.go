@@ -0,0 +1,43 @@
+package experiments
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	rl "github.com/yourusername/distributed-task-queue/src/api/ratelimit"
+)
+
+// allowRequest applies the rate limit and writes the 429 response if the
+// client is over it
+func allowRequest(c *gin.Context) bool {
+	return allowRequestN(c, 1)
+}
+
+// allowRequestN is allowRequest for a request that counts as n requests
+func allowRequestN(c *gin.Context, n int) bool {
+	clientID := c.ClientIP()
+	rateLimitResult, err := rl.AllowN(c.Request.Context(), clientID, n)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "rate limiter error",
+		})
+		return false
+	}
+
+	// Set standard rate limit headers for all responses
+	c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", rateLimitResult.Limit))
+	c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", rateLimitResult.Remaining))
+
+	if !rateLimitResult.Allowed {
+		// Set Retry-After header when rate limited
+		c.Header("Retry-After", fmt.Sprintf("%d", rateLimitResult.RetryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "rate limit exceeded",
+			"remaining":   rateLimitResult.Remaining,
+			"retry_after": rateLimitResult.RetryAfter,
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package experiments
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+	"github.com/yourusername/distributed-task-queue/src/api/scheduler"
+	"github.com/yourusername/distributed-task-queue/src/jobs"
+	redis "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+func Schedules(router *gin.Engine) {
+	// register, list, inspect and delete recurring (cron) task definitions
+	router.POST("/schedules", postSchedule)
+	router.GET("/schedules", getSchedules)
+	router.GET("/schedules/:id", getScheduleByID)
+	router.DELETE("/schedules/:id", deleteSchedule)
+}
+
+// postSchedule registers a recurring task that fires on every cron tick
+func postSchedule(c *gin.Context) {
+	var req models.ScheduleRequest
+
+	// Bind and validate JSON request
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Validate job_type against the registered handlers
+	if err := jobs.CheckJobType(req.JobType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Validate target queue
+	if req.Queue == "" {
+		req.Queue = redis.FIFO_QUEUE_NAME
+	}
+	if !redis.ValidQueueName(req.Queue) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "queue must be 1-64 letters, digits, '-' or '_'",
+		})
+		return
+	}
+
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+
+	// Validate cron expression and timezone by computing the first tick
+	now := time.Now()
+	next, err := scheduler.Next(req.Cron, req.Timezone, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	schedule := models.Schedule{
+		ID:        uuid.New().String(),
+		Cron:      req.Cron,
+		JobType:   req.JobType,
+		Payload:   req.Payload,
+		Queue:     req.Queue,
+		Timezone:  req.Timezone,
+		CreatedAt: now,
+		NextRunAt: next,
+	}
+
+	if err := redis.SaveSchedule(&schedule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store schedule",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Schedule created successfully",
+		"schedule": schedule,
+	})
+}
+
+// getSchedules returns every recurring task definition
+func getSchedules(c *gin.Context) {
+	schedules, err := redis.ListSchedules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list schedules",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedules": schedules,
+		"total":     len(schedules),
+	})
+}
+
+// getScheduleByID returns one recurring task definition
+func getScheduleByID(c *gin.Context) {
+	scheduleID := c.Param("id")
+
+	schedule, err := redis.GetSchedule(scheduleID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Schedule not found",
+			"id":    scheduleID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// deleteSchedule stops a recurring task from firing again.
+// Tasks it already created are left untouched.
+func deleteSchedule(c *gin.Context) {
+	scheduleID := c.Param("id")
+
+	deleted, err := redis.DeleteSchedule(scheduleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete schedule",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Schedule not found",
+			"id":    scheduleID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Schedule deleted",
+		"id":      scheduleID,
+	})
+}
@@ -0,0 +1,61 @@
+package experiments
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	redis "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+func Workers(router *gin.Engine) {
+	// list the live workers
+	router.GET("/workers", getWorkers)
+	// inspect one worker, e.g. to see what it is running
+	router.GET("/workers/:id", getWorkerByID)
+}
+
+// getWorkers returns every worker that heartbeated recently, with the number
+// of slots busy across them
+func getWorkers(c *gin.Context) {
+	workers, err := redis.ListWorkers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list workers",
+		})
+		return
+	}
+
+	slots, busy := 0, 0
+	for _, worker := range workers {
+		slots += worker.Concurrency
+		busy += len(worker.CurrentTasks)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"workers":    workers,
+		"total":      len(workers),
+		"slots":      slots,
+		"busy_slots": busy,
+	})
+}
+
+func getWorkerByID(c *gin.Context) {
+	workerID := c.Param("id")
+
+	worker, err := redis.GetWorker(workerID)
+	if err == redis.Nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Worker not found",
+			"id":    workerID,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve worker",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, worker)
+}
@@ -0,0 +1,408 @@
+package experiments
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+	"github.com/yourusername/distributed-task-queue/src/jobs"
+	redis "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+// maximum number of tasks in one workflow
+const maxWorkflowTasks = 500
+
+// workflow IDs and the IDs of tasks within a workflow
+var workflowIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func Workflows(router *gin.Engine) {
+	// submit a set of tasks with depends_on relationships
+	router.POST("/workflows", postWorkflow)
+	// shortcuts: a chain runs tasks in sequence, a group in parallel and a
+	// group with a callback (chord) runs the callback once all succeeded
+	router.POST("/chains", postChain)
+	router.POST("/groups", postGroup)
+	// aggregated status of a workflow, chain or group and its tasks
+	router.GET("/workflows/:id", getWorkflow)
+}
+
+// postWorkflow handles workflow submission. Tasks without depends_on are
+// enqueued right away, the others once all their parents succeeded.
+// Task IDs in the workflow are local; the stored task ID is
+// "<workflow id>.<task id>".
+func postWorkflow(c *gin.Context) {
+	if !allowRequest(c) {
+		return
+	}
+
+	var req models.WorkflowRequest
+
+	// Bind and validate JSON request
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	submitWorkflow(c, req.ID, "dag", req.Tasks)
+}
+
+// postChain handles chain submission: every task depends on the one before
+// it and gets its result as input
+func postChain(c *gin.Context) {
+	if !allowRequest(c) {
+		return
+	}
+
+	var req models.ChainRequest
+
+	// Bind and validate JSON request
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := defaultMemberIDs(req.Tasks, "step"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	for i := 1; i < len(req.Tasks); i++ {
+		req.Tasks[i].DependsOn = []string{req.Tasks[i-1].ID}
+	}
+
+	submitWorkflow(c, req.ID, "chain", req.Tasks)
+}
+
+// postGroup handles group submission: all tasks run in parallel and are
+// tracked as one workflow. With a callback the group is a chord, and the
+// callback runs once every task succeeded, with all results as input.
+func postGroup(c *gin.Context) {
+	if !allowRequest(c) {
+		return
+	}
+
+	var req models.GroupRequest
+
+	// Bind and validate JSON request
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := defaultMemberIDs(req.Tasks, "task"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if req.Callback == nil {
+		submitWorkflow(c, req.ID, "group", req.Tasks)
+		return
+	}
+
+	callback := *req.Callback
+	if len(callback.DependsOn) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "depends_on is set automatically for the callback",
+		})
+		return
+	}
+	if callback.ID == "" {
+		callback.ID = "callback"
+	}
+	for _, t := range req.Tasks {
+		callback.DependsOn = append(callback.DependsOn, t.ID)
+	}
+
+	submitWorkflow(c, req.ID, "chord", append(req.Tasks, callback))
+}
+
+// defaultMemberIDs rejects depends_on in chain and group members, whose
+// dependencies are implied, and names members without an ID "<prefix>-<n>"
+func defaultMemberIDs(tasks []models.WorkflowTaskRequest, prefix string) error {
+	for i := range tasks {
+		if len(tasks[i].DependsOn) > 0 {
+			return errors.New("depends_on is set automatically for chains and groups")
+		}
+		if tasks[i].ID == "" {
+			tasks[i].ID = fmt.Sprintf("%s-%d", prefix, i+1)
+		}
+	}
+	return nil
+}
+
+// submitWorkflow validates and creates a workflow of the given kind and
+// writes the response
+func submitWorkflow(c *gin.Context, workflowID, kind string, requests []models.WorkflowTaskRequest) {
+	// Generate workflow ID if not provided (for idempotency)
+	if workflowID == "" {
+		workflowID = uuid.New().String()
+	}
+	if !workflowIDPattern.MatchString(workflowID) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "id must be 1-64 letters, digits, '-' or '_'",
+		})
+		return
+	}
+
+	if err := validateWorkflow(requests); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Create the workflow and its tasks
+	submittedAt := time.Now()
+	workflow := models.Workflow{
+		ID:        workflowID,
+		Kind:      kind,
+		TaskIDs:   make([]string, 0, len(requests)),
+		CreatedAt: submittedAt,
+	}
+	tasks := make([]*models.Task, 0, len(requests))
+	for _, t := range requests {
+		priority := redis.DefaultPriority(t.JobType)
+		if t.Priority != nil {
+			priority = *t.Priority
+		}
+		queue := t.Queue
+		if queue == "" {
+			queue = redis.FIFO_QUEUE_NAME
+		}
+		status := "queued"
+		var dependsOn []string
+		if len(t.DependsOn) > 0 {
+			status = "pending"
+			for _, parent := range t.DependsOn {
+				dependsOn = append(dependsOn, workflowTaskID(workflowID, parent))
+			}
+		}
+
+		task := &models.Task{
+			ID:          workflowTaskID(workflowID, t.ID),
+			JobType:     t.JobType,
+			Payload:     t.Payload,
+			Status:      status,
+			Priority:    priority,
+			Queue:       queue,
+			SubmittedAt: submittedAt,
+			RetryCount:  0,
+			WorkflowID:  workflowID,
+			DependsOn:   dependsOn,
+			ResultTTL:   t.ResultTTL,
+			Timeout:     t.Timeout,
+		}
+		workflow.TaskIDs = append(workflow.TaskIDs, task.ID)
+		tasks = append(tasks, task)
+	}
+
+	// Store all tasks and enqueue the roots in one atomic step, unless a
+	// workflow with this ID, or a task with one of its task IDs, already exists
+	created, takenID, err := redis.CreateWorkflow(&workflow, tasks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create workflow",
+		})
+		return
+	}
+	if takenID != "" {
+		// Never overwrite a task that was submitted on its own
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Task ID already used by another task",
+			"id":    takenID,
+		})
+		return
+	}
+
+	if !created {
+		// Workflow already exists (idempotency), return its current state
+		existing, err := redis.GetWorkflow(workflowID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to retrieve existing workflow",
+			})
+			return
+		}
+		view, err := workflowView(existing)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to retrieve workflow tasks",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Workflow already exists",
+			"workflow": view,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  fmt.Sprintf("Workflow created successfully (%s)", kind),
+		"workflow": workflowSummary(&workflow, tasks),
+	})
+}
+
+// getWorkflow returns a workflow with the status of each of its tasks
+func getWorkflow(c *gin.Context) {
+	workflowID := c.Param("id")
+
+	workflow, err := redis.GetWorkflow(workflowID)
+	if err == redis.Nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Workflow not found",
+			"id":    workflowID,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve workflow",
+		})
+		return
+	}
+
+	view, err := workflowView(workflow)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve workflow tasks",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// workflowTaskID is the stored ID of a task within a workflow
+func workflowTaskID(workflowID, taskID string) string {
+	return workflowID + "." + taskID
+}
+
+// validateWorkflow checks every task of a workflow and that depends_on
+// forms a DAG over tasks of the same workflow
+func validateWorkflow(tasks []models.WorkflowTaskRequest) error {
+	if len(tasks) == 0 || len(tasks) > maxWorkflowTasks {
+		return fmt.Errorf("a workflow must have between 1 and %d tasks", maxWorkflowTasks)
+	}
+
+	byID := make(map[string]*models.WorkflowTaskRequest, len(tasks))
+	for i := range tasks {
+		t := &tasks[i]
+		if !workflowIDPattern.MatchString(t.ID) {
+			return fmt.Errorf("task id %q must be 1-64 letters, digits, '-' or '_'", t.ID)
+		}
+		if byID[t.ID] != nil {
+			return fmt.Errorf("task id %q is used twice", t.ID)
+		}
+		byID[t.ID] = t
+
+		if err := jobs.CheckJobType(t.JobType); err != nil {
+			return fmt.Errorf("task %q: %v", t.ID, err)
+		}
+		if t.Priority != nil && (*t.Priority < 0 || *t.Priority > redis.MAX_PRIORITY) {
+			return fmt.Errorf("task %q: priority must be between 0 and %d", t.ID, redis.MAX_PRIORITY)
+		}
+		if t.ResultTTL < 0 {
+			return fmt.Errorf("task %q: result_ttl must not be negative", t.ID)
+		}
+		if t.Timeout < 0 {
+			return fmt.Errorf("task %q: timeout must not be negative", t.ID)
+		}
+		if t.Queue != "" && !redis.ValidQueueName(t.Queue) {
+			return fmt.Errorf("task %q: queue must be 1-64 letters, digits, '-' or '_'", t.ID)
+		}
+	}
+
+	// Kahn's algorithm: if some tasks never run out of parents there is a cycle
+	parents := make(map[string]int, len(tasks))
+	children := make(map[string][]string, len(tasks))
+	for _, t := range tasks {
+		seen := make(map[string]bool, len(t.DependsOn))
+		for _, parent := range t.DependsOn {
+			if byID[parent] == nil {
+				return fmt.Errorf("task %q depends on unknown task %q", t.ID, parent)
+			}
+			if seen[parent] {
+				return fmt.Errorf("task %q lists %q twice in depends_on", t.ID, parent)
+			}
+			seen[parent] = true
+			parents[t.ID]++
+			children[parent] = append(children[parent], t.ID)
+		}
+	}
+
+	var ready []string
+	for _, t := range tasks {
+		if parents[t.ID] == 0 {
+			ready = append(ready, t.ID)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		id := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		visited++
+		for _, child := range children[id] {
+			parents[child]--
+			if parents[child] == 0 {
+				ready = append(ready, child)
+			}
+		}
+	}
+	if visited != len(tasks) {
+		return errors.New("depends_on must not contain cycles")
+	}
+	return nil
+}
+
+// workflowView loads the tasks of a workflow and summarizes them
+func workflowView(workflow *models.Workflow) (gin.H, error) {
+	tasks, err := redis.GetTasks(workflow.TaskIDs)
+	if err != nil {
+		return nil, err
+	}
+	return workflowSummary(workflow, tasks), nil
+}
+
+// workflowSummary aggregates task statuses into the workflow's status:
+// "running" while any task can still run, then "success" if every task
+// succeeded and "failed" otherwise
+func workflowSummary(workflow *models.Workflow, tasks []*models.Task) gin.H {
+	counts := make(map[string]int)
+	for _, task := range tasks {
+		counts[task.Status]++
+	}
+
+	status := "failed"
+	switch {
+	case counts["pending"]+counts["scheduled"]+counts["queued"]+counts["running"] > 0:
+		status = "running"
+	case counts["success"] == len(workflow.TaskIDs):
+		status = "success"
+	}
+
+	return gin.H{
+		"id":         workflow.ID,
+		"kind":       workflow.Kind,
+		"status":     status,
+		"created_at": workflow.CreatedAt,
+		"counts":     counts,
+		"tasks":      tasks,
+	}
+}
@@ -0,0 +1,49 @@
+package experiments
+
+import (
+	"strings"
+	"testing"
+
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// task returns a workflow task of the short job type depending on parents
+func task(id string, parents ...string) models.WorkflowTaskRequest {
+	return models.WorkflowTaskRequest{ID: id, JobType: "short", DependsOn: parents}
+}
+
+func TestValidateWorkflow(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		tasks []models.WorkflowTaskRequest
+		err   string // substring of the expected error, "" for none
+	}{
+		{"single task", []models.WorkflowTaskRequest{task("a")}, ""},
+		{"diamond", []models.WorkflowTaskRequest{
+			task("a"), task("b", "a"), task("c", "a"), task("d", "b", "c"),
+		}, ""},
+		{"parent listed after child", []models.WorkflowTaskRequest{task("b", "a"), task("a")}, ""},
+		{"empty", nil, "between 1 and"},
+		{"cycle", []models.WorkflowTaskRequest{
+			task("a", "c"), task("b", "a"), task("c", "b"),
+		}, "cycles"},
+		{"cycle below a root", []models.WorkflowTaskRequest{
+			task("root"), task("a", "root", "b"), task("b", "a"),
+		}, "cycles"},
+		{"self dependency", []models.WorkflowTaskRequest{task("a", "a")}, "cycles"},
+		{"duplicate id", []models.WorkflowTaskRequest{task("a"), task("a")}, `"a" is used twice`},
+		{"unknown parent", []models.WorkflowTaskRequest{task("a"), task("b", "x")}, `unknown task "x"`},
+		{"parent listed twice", []models.WorkflowTaskRequest{task("a"), task("b", "a", "a")}, "twice in depends_on"},
+		{"invalid id", []models.WorkflowTaskRequest{task("a.b")}, "must be 1-64"},
+	} {
+		err := validateWorkflow(tc.tasks)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		case tc.err != "" && err == nil:
+			t.Errorf("%s: no error, want one containing %q", tc.name, tc.err)
+		case tc.err != "" && !strings.Contains(err.Error(), tc.err):
+			t.Errorf("%s: error %q, want one containing %q", tc.name, err, tc.err)
+		}
+	}
+}
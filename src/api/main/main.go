@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/gin-gonic/gin"
 	experiments "github.com/yourusername/distributed-task-queue/src/api/experiments"
+	"github.com/yourusername/distributed-task-queue/src/api/scheduler"
 	redis "github.com/yourusername/distributed-task-queue/src/redis"
 )
 
@@ -16,6 +17,22 @@ func main() {
 
 	// Experiment2 includes Experiment1 endpoints + queue status endpoint
 	experiments.Experiment2(router)
+	// Dead-letter queue inspection and recovery for permanently failed tasks
+	experiments.DeadLetterQueue(router)
+	// Recurring (cron) task definitions
+	experiments.Schedules(router)
+	// Named queues for isolating workloads
+	experiments.Queues(router)
+	// Task dependencies (DAG workflows)
+	experiments.Workflows(router)
+	// Live workers, from their heartbeats
+	experiments.Workers(router)
+	// Many tasks in one request
+	experiments.Batch(router)
+
+	// Fire due cron schedules; safe to run on every API instance
+	scheduler.Start()
+
 	// "Run()" attaches router to an http server and start the server
 	// router.Run("localhost:8080")
 	router.Run(":8080")
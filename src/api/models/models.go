@@ -5,16 +5,34 @@ import (
 )
 
 type Task struct {
-	ID          string    `json:"id"`
-	JobType     string    `json:"job_type"`     // "short" or "long"
-	Payload     string    `json:"payload"`      // task-specific data
-	Status      string    `json:"status"`       // "queued", "running", "success", "failed"
-	SubmittedAt time.Time `json:"submitted_at"`
-	StartedAt   *time.Time `json:"started_at,omitempty"`
-	CompletedAt *time.Time `json:"completed_at,omitempty"`
-	RetryCount  int       `json:"retry_count"`
-	Result      string    `json:"result,omitempty"`
-	Error       string    `json:"error,omitempty"`
+	ID          string        `json:"id"`
+	JobType     string        `json:"job_type"`        // name of a registered handler, see package jobs
+	Payload     string        `json:"payload"`         // task-specific data
+	Status      string        `json:"status"`          // "pending", "scheduled", "queued", "running", "success", "failed", "cancelled", "skipped"
+	Priority    int           `json:"priority"`        // 0-255, higher runs first in the priority queue
+	Queue       string        `json:"queue,omitempty"` // "fifo", "priority" or a named queue
+	SubmittedAt time.Time     `json:"submitted_at"`
+	ScheduledAt *time.Time    `json:"scheduled_at,omitempty"` // planned run time of a delayed task
+	StartedAt   *time.Time    `json:"started_at,omitempty"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+	RetryCount  int           `json:"retry_count"`
+	Result      string        `json:"result,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	WorkflowID  string        `json:"workflow_id,omitempty"`
+	DependsOn   []string      `json:"depends_on,omitempty"` // IDs of tasks that must succeed first
+	Inputs      []string      `json:"inputs,omitempty"`     // results of DependsOn, in the same order
+	ResultTTL   int           `json:"result_ttl,omitempty"` // seconds to keep the record once finished, overrides the retention policy
+	UniqueKey   string        `json:"unique_key,omitempty"` // key duplicates are detected by, see UniqueFor
+	UniqueFor   int           `json:"unique_for,omitempty"` // seconds during which tasks with the same UniqueKey are duplicates
+	Timeout     int           `json:"timeout,omitempty"`    // seconds the task may run, 0 for the default of its job type
+	Progress    *TaskProgress `json:"progress,omitempty"`   // last progress reported by the running task
+}
+
+// TaskProgress is the last progress a running task reported
+type TaskProgress struct {
+	Percent   int       `json:"percent"` // 0-100
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // TaskRequest represents the request body for submitting a task
@@ -22,4 +40,121 @@ type TaskRequest struct {
 	ID      string `json:"id,omitempty"` // Optional: client can provide ID for idempotency
 	JobType string `json:"job_type" binding:"required"`
 	Payload string `json:"payload"`
-}
\ No newline at end of file
+	// Optional: 0-255, higher runs first in the priority queue; defaults by job type
+	Priority *int `json:"priority,omitempty"`
+	// Optional: delay execution until RunAt (RFC3339) or by DelaySeconds, not both
+	RunAt        *time.Time `json:"run_at,omitempty"`
+	DelaySeconds int        `json:"delay_seconds,omitempty"`
+	// Optional: seconds to keep the task record once it finished
+	ResultTTL int `json:"result_ttl,omitempty"`
+	// Optional: for UniqueFor seconds, submissions with the same UniqueKey (or
+	// the same job_type and payload if no key is given) return this task
+	UniqueKey string `json:"unique_key,omitempty"`
+	UniqueFor int    `json:"unique_for,omitempty"`
+	// Optional: seconds the task may run before it times out; defaults by job type
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// BatchTaskRequest is the request body for submitting many tasks at once
+type BatchTaskRequest struct {
+	// Optional: "fifo" (default), "priority" or a named queue, for every task
+	Queue string        `json:"queue,omitempty"`
+	Tasks []TaskRequest `json:"tasks" binding:"required"`
+}
+
+// Workflow groups tasks submitted together with dependencies between them
+type Workflow struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"` // "dag", "chain", "group" or "chord"
+	TaskIDs   []string  `json:"task_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkflowRequest represents the request body for submitting a workflow
+type WorkflowRequest struct {
+	ID    string                `json:"id,omitempty"` // Optional: client can provide ID for idempotency
+	Tasks []WorkflowTaskRequest `json:"tasks" binding:"required"`
+}
+
+// WorkflowTaskRequest is one task of a workflow. Its ID only has to be unique
+// within the workflow and is what other tasks list in depends_on.
+type WorkflowTaskRequest struct {
+	ID        string   `json:"id"`
+	JobType   string   `json:"job_type"`
+	Payload   string   `json:"payload"`
+	Priority  *int     `json:"priority,omitempty"`
+	Queue     string   `json:"queue,omitempty"` // Optional: "fifo" (default), "priority" or a named queue
+	DependsOn []string `json:"depends_on,omitempty"`
+	ResultTTL int      `json:"result_ttl,omitempty"` // Optional: seconds to keep the record once finished
+	Timeout   int      `json:"timeout,omitempty"`    // Optional: seconds the task may run
+}
+
+// ChainRequest represents the request body for submitting a chain: tasks run
+// one after another, each getting the previous task's result as input
+type ChainRequest struct {
+	ID    string                `json:"id,omitempty"` // Optional: client can provide ID for idempotency
+	Tasks []WorkflowTaskRequest `json:"tasks" binding:"required"`
+}
+
+// GroupRequest represents the request body for submitting a group of tasks
+// that run in parallel. With a callback it is a chord: the callback runs once
+// every task of the group succeeded, with all their results as input.
+type GroupRequest struct {
+	ID       string                `json:"id,omitempty"` // Optional: client can provide ID for idempotency
+	Tasks    []WorkflowTaskRequest `json:"tasks" binding:"required"`
+	Callback *WorkflowTaskRequest  `json:"callback,omitempty"`
+}
+
+// DeadLetterEntry records why a task ended up in the dead-letter queue
+type DeadLetterEntry struct {
+	TaskID     string    `json:"task_id"`
+	JobType    string    `json:"job_type"`
+	Reason     string    `json:"reason"`               // why the task was given up on
+	LastError  string    `json:"last_error,omitempty"` // error of the last failed attempt
+	RetryCount int       `json:"retry_count"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// WaitingTask describes the task that has been waiting longest in a queue
+type WaitingTask struct {
+	TaskID       string    `json:"task_id"`
+	WaitingSince time.Time `json:"waiting_since"`
+	WaitSeconds  float64   `json:"wait_seconds"`
+}
+
+// WorkerInfo describes a live worker process, see GET /workers
+type WorkerInfo struct {
+	ID            string    `json:"id"`
+	Hostname      string    `json:"hostname"`
+	Queues        []string  `json:"queues"` // queues it consumes, with their weights
+	Strict        bool      `json:"strict"` // queues are consumed in the listed order
+	Mode          string    `json:"mode"`   // "simple" or "retry"
+	Concurrency   int       `json:"concurrency"`
+	Status        string    `json:"status"` // "running", or "stopping" during its grace period
+	StartedAt     time.Time `json:"started_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	CurrentTasks  []string  `json:"current_tasks"` // IDs of the tasks it is running
+}
+
+// Schedule is a recurring task definition, materialized into a Task on every cron tick
+type Schedule struct {
+	ID         string     `json:"id"`
+	Cron       string     `json:"cron"` // standard 5-field cron expression
+	JobType    string     `json:"job_type"`
+	Payload    string     `json:"payload"`
+	Queue      string     `json:"queue"`    // "fifo", "priority" or a named queue
+	Timezone   string     `json:"timezone"` // IANA name the cron expression is evaluated in
+	CreatedAt  time.Time  `json:"created_at"`
+	NextRunAt  time.Time  `json:"next_run_at"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	LastTaskID string     `json:"last_task_id,omitempty"`
+}
+
+// ScheduleRequest represents the request body for registering a recurring task
+type ScheduleRequest struct {
+	Cron     string `json:"cron" binding:"required"`
+	JobType  string `json:"job_type" binding:"required"`
+	Payload  string `json:"payload"`
+	Queue    string `json:"queue"`    // Optional: "fifo" (default), "priority" or a named queue
+	Timezone string `json:"timezone"` // Optional: defaults to "UTC"
+}
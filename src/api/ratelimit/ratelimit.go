@@ -21,6 +21,13 @@ type RateLimitResult struct {
 
 // Allow checks if a request is allowed and returns rate limit information
 func Allow(ctx context.Context, clientID string) (*RateLimitResult, error) {
+	return AllowN(ctx, clientID, 1)
+}
+
+// AllowN checks if a request costing n units (e.g. a batch of n tasks) is
+// allowed and returns rate limit information. The units are charged even if
+// the request is rejected, like a rejected single request is.
+func AllowN(ctx context.Context, clientID string, n int) (*RateLimitResult, error) {
 	now := time.Now()
 	// All requests within the same minute will share the same window
 	window := now.Format("200601021504") // e.g. 202512051630
@@ -29,12 +36,12 @@ func Allow(ctx context.Context, clientID string) (*RateLimitResult, error) {
 	rc := redis.GetRedisClient()
 
 	// Atomically increment the counter for this client in the current window
-	count, err := rc.Incr(ctx, key).Result()
+	count, err := rc.IncrBy(ctx, key, int64(n)).Result()
 	if err != nil {
 		return nil, err
 	}
-	// Set 2-minute expiration ONLY on first request (count == 1) to auto-clean old keys.
-	if count == 1 {
+	// Set 2-minute expiration ONLY on first request (count == n) to auto-clean old keys.
+	if count == int64(n) {
 		rc.Expire(ctx, key, 2*time.Minute)
 	}
 
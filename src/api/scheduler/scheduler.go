@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+	redis "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+// how often the scheduler looks for due schedules
+const pollInterval = 1 * time.Second
+
+// maximum number of schedules fired per poll
+const batchSize = 128
+
+// Next returns the first tick of a cron expression after 'after', evaluated
+// in the given IANA timezone. It also serves to validate both.
+func Next(expr, timezone string, after time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	sched, err := cron.ParseStandard(expr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	next := sched.Next(after.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never fires", expr)
+	}
+	return next, nil
+}
+
+// Start runs the scheduler loop in the background. Every instance of the API
+// can run it: each tick creates a task with an ID derived from the schedule
+// and the tick time, so the atomic create dedups it, and only one instance
+// gets to advance the schedule to its next tick.
+func Start() {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ticks, err := redis.DueSchedules(batchSize)
+			if err != nil {
+				log.Printf("schedule scan error: %v", err)
+				continue
+			}
+			for _, tick := range ticks {
+				if err := fire(tick); err != nil {
+					log.Printf("schedule %s tick %s error: %v",
+						tick.ScheduleID, tick.At.Format(time.RFC3339), err)
+				}
+			}
+		}
+	}()
+}
+
+// fire materializes the task of one schedule tick and advances the schedule.
+// Ticks missed while no scheduler was running are collapsed into this one.
+func fire(tick redis.ScheduleTick) error {
+	schedule, err := redis.GetSchedule(tick.ScheduleID)
+	if err == redis.Nil {
+		// Deleted between the scan and now
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	task := models.Task{
+		ID:          fmt.Sprintf("schedule-%s-%d", schedule.ID, tick.At.Unix()),
+		JobType:     schedule.JobType,
+		Payload:     schedule.Payload,
+		Status:      "queued",
+		Priority:    redis.DefaultPriority(schedule.JobType),
+		Queue:       schedule.Queue,
+		SubmittedAt: time.Now(),
+		RetryCount:  0,
+	}
+
+	existingID, err := redis.CreateTaskInQueue(&task, nil)
+	if err != nil {
+		return err
+	}
+
+	next, err := Next(schedule.Cron, schedule.Timezone, time.Now())
+	if err != nil {
+		return err
+	}
+	at := tick.At
+	schedule.LastRunAt = &at
+	schedule.LastTaskID = task.ID
+	schedule.NextRunAt = next
+
+	if _, err := redis.AdvanceSchedule(schedule, tick.At); err != nil {
+		return err
+	}
+	if existingID == "" {
+		log.Printf("→ Schedule %s fired task %s (next run %s)",
+			schedule.ID, task.ID, next.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// The built-in job types simulate work of a fixed length, which is what the
+// experiments measure: short jobs take 500ms, long jobs 3 seconds.
+func init() {
+	Register("short", sleepHandler(500*time.Millisecond))
+	Register("long", sleepHandler(3*time.Second))
+}
+
+// Simulated reports whether jobType is one of the built-in job types. Retry
+// workers inject their random failures into these only, so tasks of real job
+// types fail only when their handler returns an error.
+func Simulated(jobType string) bool {
+	return jobType == "short" || jobType == "long"
+}
+
+// sleepHandler returns a handler that sleeps for d, or until ctx is done,
+// reporting progress every tenth of the way
+func sleepHandler(d time.Duration) Handler {
+	return func(ctx context.Context, task *models.Task) (string, error) {
+		ticker := time.NewTicker(d / 10)
+		defer ticker.Stop()
+		for step := 1; step <= 10; step++ {
+			select {
+			case <-ticker.C:
+				ReportProgress(ctx, step*10, fmt.Sprintf("step %d of 10", step))
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		return "Task completed successfully", nil
+	}
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+	redis "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+const (
+	// progressInterval is the minimum time between two stored progress updates
+	progressInterval = 1 * time.Second
+	// maxProgressMessage bounds the length of a progress message
+	maxProgressMessage = 200
+)
+
+// progressReporter stores the progress of one running task, throttled to
+// one update per progressInterval. It never touches the task itself, which
+// belongs to the worker.
+type progressReporter struct {
+	sync.Mutex
+	workerID string
+	taskID   string
+	latest   *models.TaskProgress
+	stored   time.Time
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context the handler of a task the worker runs can
+// report progress through
+func WithProgress(ctx context.Context, workerID, taskID string) context.Context {
+	return context.WithValue(ctx, progressKey{}, &progressReporter{workerID: workerID, taskID: taskID})
+}
+
+// LastProgress returns the latest progress reported under ctx, stored or
+// not, for the worker to store with the task's outcome. It is nil if the
+// handler reported none.
+func LastProgress(ctx context.Context) *models.TaskProgress {
+	reporter, ok := ctx.Value(progressKey{}).(*progressReporter)
+	if !ok {
+		return nil
+	}
+	reporter.Lock()
+	defer reporter.Unlock()
+	return reporter.latest
+}
+
+// ReportProgress records how far the task running under ctx is: percent
+// complete (0-100) and a short message. Updates within progressInterval of
+// the last stored one are only kept in memory; the latest progress is
+// always stored with the task's outcome.
+func ReportProgress(ctx context.Context, percent int, message string) {
+	reporter, ok := ctx.Value(progressKey{}).(*progressReporter)
+	if !ok {
+		return
+	}
+	// Once ctx is done the worker stores the task's outcome
+	if ctx.Err() != nil {
+		return
+	}
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	if len(message) > maxProgressMessage {
+		message = message[:maxProgressMessage]
+	}
+
+	reporter.Lock()
+	defer reporter.Unlock()
+
+	now := time.Now()
+	progress := models.TaskProgress{
+		Percent:   percent,
+		Message:   message,
+		UpdatedAt: now,
+	}
+	reporter.latest = &progress
+	if now.Sub(reporter.stored) < progressInterval {
+		return
+	}
+	reporter.stored = now
+	// Only written while the task is still running, so an update racing
+	// with the outcome can't overwrite it
+	if _, err := redis.StoreProgress(reporter.workerID, reporter.taskID, progress); err != nil {
+		log.Printf("Failed to store progress of task %s: %v", reporter.taskID, err)
+	}
+}
@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// ============================================
+// Job Handlers
+// ============================================
+//
+// Every job type is run by a handler registered under its name. Handlers
+// register themselves from init functions in this package, so the API
+// (which rejects unknown job types at submission) and the worker (which
+// dispatches dequeued tasks) are always built with the same job types.
+
+// Handler runs one task and returns its result. It should return once ctx is
+// done: the task was cancelled or ran past its timeout. Errors are retried by
+// retry workers unless wrapped with Permanent.
+type Handler func(ctx context.Context, task *models.Task) (string, error)
+
+var registry = struct {
+	sync.RWMutex
+	handlers map[string]Handler
+}{handlers: make(map[string]Handler)}
+
+// Register makes a handler available for a job type.
+// It panics if the job type is empty or already registered.
+func Register(jobType string, handler Handler) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if jobType == "" || handler == nil {
+		panic("jobs: Register needs a job type and a handler")
+	}
+	if _, ok := registry.handlers[jobType]; ok {
+		panic(fmt.Sprintf("jobs: job type %q registered twice", jobType))
+	}
+	registry.handlers[jobType] = handler
+}
+
+// Lookup returns the handler registered for a job type
+func Lookup(jobType string) (Handler, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	handler, ok := registry.handlers[jobType]
+	return handler, ok
+}
+
+// JobTypes returns the registered job types in alphabetical order
+func JobTypes() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	types := make([]string, 0, len(registry.handlers))
+	for jobType := range registry.handlers {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// CheckJobType returns an error listing the registered job types if jobType
+// isn't one of them
+func CheckJobType(jobType string) error {
+	if _, ok := Lookup(jobType); ok {
+		return nil
+	}
+	return fmt.Errorf("job_type must be one of: %s", strings.Join(JobTypes(), ", "))
+}
+
+// permanentError marks an error retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error so the task fails right away instead of being retried
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,70 @@
+package redis
+
+import (
+	"github.com/go-redis/redis/v8"
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// ============================================
+// Batch Submission
+// ============================================
+//
+// CreateTasksInQueue creates many tasks in one pipelined round trip. Every
+// task goes through createTaskScript on its own, so each one is created
+// atomically and deduplicated exactly like a single submission, but the
+// batch as a whole is not atomic: some tasks may be created while others
+// are duplicates or fail.
+
+// CreateTasksInQueue stores each task and pushes it into the queue it is
+// addressed to (task.Queue), like CreateTaskInQueue. records[i], if not nil,
+// is stored as the idempotency record of tasks[i].
+// For each task it returns "" if the task was created or the ID of the
+// existing task it duplicates, and the error creating it, if any. The
+// returned error is set when the batch could not be sent at all.
+func CreateTasksInQueue(tasks []*models.Task, records []*IdempotencyRecord) ([]string, []error, error) {
+	existing := make([]string, len(tasks))
+	errs := make([]error, len(tasks))
+	if len(tasks) == 0 {
+		return existing, errs, nil
+	}
+
+	// Scripts can only be called by SHA inside a pipeline, so make sure the
+	// server has it
+	if err := createTaskScript.Load(ctx, rdb).Err(); err != nil {
+		return nil, nil, err
+	}
+
+	pipe := rdb.Pipeline()
+	registered := make(map[string]bool)
+	cmds := make([]*redis.Cmd, len(tasks))
+	for i, task := range tasks {
+		queueKey, kind, score := QueueKey(task.Queue), "list", 0.0
+		switch task.Queue {
+		case FIFO_QUEUE_NAME:
+		case PRIORITY_QUEUE_NAME:
+			kind, score = "zset", taskScore(task)
+		default:
+			if !registered[task.Queue] {
+				pipe.SAdd(ctx, QUEUE_REGISTRY_KEY, task.Queue)
+				registered[task.Queue] = true
+			}
+		}
+
+		keys, args, err := createTaskArgs(task, queueKey, kind, score, records[i])
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		cmds[i] = createTaskScript.EvalSha(ctx, pipe, keys, args...)
+	}
+
+	// Failures are reported per task below
+	_, _ = pipe.Exec(ctx)
+
+	for i, cmd := range cmds {
+		if cmd != nil {
+			existing[i], errs[i] = cmd.Text()
+		}
+	}
+	return existing, errs, nil
+}
@@ -0,0 +1,113 @@
+package redis
+
+import (
+	"time"
+)
+
+// ============================================
+// Task Cancellation
+// ============================================
+
+const (
+	// CANCEL_CHANNEL is the pub/sub channel workers listen on for cancelled task IDs
+	CANCEL_CHANNEL = "task:cancel"
+	// CANCEL_FLAG_PREFIX + taskID marks a running task as cancelled, for
+	// workers that pick the task up after the message was published
+	CANCEL_FLAG_PREFIX = "task:cancel_requested:"
+	// CANCEL_FLAG_TTL bounds how long a cancellation request is remembered
+	CANCEL_FLAG_TTL = 24 * time.Hour
+)
+
+// Outcomes of CancelTask
+const (
+	// CancelRemoved means the task was taken out of every queue and will not run
+	CancelRemoved = "removed"
+	// CancelSignalled means the task is running and its worker has been asked to stop
+	CancelSignalled = "signalled"
+)
+
+// cancelTaskScript removes a task from every queue it may be waiting in, or
+// signals its worker if it is already in-flight. Finished tasks are left
+// alone and their status is returned instead.
+// KEYS: task key, list queue of the task, priority queue, retry zset, retry meta,
+// inflight meta, cancel flag, scheduled zset, scheduled meta, pending meta
+// ARGV: task ID, cancel flag TTL (ms), cancel channel
+var cancelTaskScript = withWaitingIndex(`
+local raw = redis.call('GET', KEYS[1])
+if not raw then
+	return false
+end
+local status = cjson.decode(raw).status
+if status == 'success' or status == 'failed' or status == 'cancelled' or status == 'skipped' then
+	return status
+end
+local removed = redis.call('LREM', KEYS[2], 0, ARGV[1])
+	+ redis.call('ZREM', KEYS[3], ARGV[1])
+	+ redis.call('ZREM', KEYS[4], ARGV[1])
+	+ redis.call('ZREM', KEYS[8], ARGV[1])
+redis.call('HDEL', KEYS[5], ARGV[1])
+redis.call('HDEL', KEYS[9], ARGV[1])
+redis.call('HDEL', KEYS[10], ARGV[1])
+unmark_waiting(ARGV[1])
+if removed == 0 and redis.call('HEXISTS', KEYS[6], ARGV[1]) == 1 then
+	redis.call('SET', KEYS[7], 1, 'PX', ARGV[2])
+	redis.call('PUBLISH', ARGV[3], ARGV[1])
+	return 'signalled'
+end
+return 'removed'
+`)
+
+// CancelTask stops a task from running. It returns CancelRemoved if the task
+// was still waiting (the caller should mark it "cancelled"), CancelSignalled
+// if a worker is running it, or the task's status if it already finished.
+// It returns redis.Nil if the task does not exist.
+func CancelTask(taskID string) (string, error) {
+	task, err := GetTask(taskID)
+	if err != nil {
+		return "", err
+	}
+
+	keys := []string{
+		TASK_RESULT_PREFIX + taskID,
+		listQueueKey(task),
+		PRIORITY_QUEUE_KEY,
+		RETRY_ZSET_KEY,
+		RETRY_META_KEY,
+		INFLIGHT_META_KEY,
+		CANCEL_FLAG_PREFIX + taskID,
+		SCHEDULED_ZSET_KEY,
+		SCHEDULED_META_KEY,
+		PENDING_META_KEY,
+	}
+	return cancelTaskScript.Run(ctx, rdb, keys,
+		taskID, CANCEL_FLAG_TTL.Milliseconds(), CANCEL_CHANNEL).Text()
+}
+
+// IsCancelRequested reports whether a cancellation was requested for a task
+func IsCancelRequested(taskID string) (bool, error) {
+	n, err := rdb.Exists(ctx, CANCEL_FLAG_PREFIX+taskID).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ClearCancelRequest forgets the cancellation request once the task has stopped
+func ClearCancelRequest(taskID string) error {
+	return rdb.Del(ctx, CANCEL_FLAG_PREFIX+taskID).Err()
+}
+
+// SubscribeCancellations returns a channel of task IDs whose cancellation was
+// requested. The subscription reconnects on its own and lives as long as the
+// Redis client.
+func SubscribeCancellations() <-chan string {
+	pubsub := rdb.Subscribe(ctx, CANCEL_CHANNEL)
+	ids := make(chan string)
+	go func() {
+		defer close(ids)
+		for msg := range pubsub.Channel() {
+			ids <- msg.Payload
+		}
+	}()
+	return ids
+}
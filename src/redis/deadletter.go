@@ -0,0 +1,201 @@
+package redis
+
+import (
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// ============================================
+// Dead-letter Queue Operations
+// ============================================
+//
+// Tasks that fail permanently are moved from the worker's in-flight
+// bookkeeping into DEAD_LETTER_ZSET_KEY, scored by the time they failed.
+// DEAD_LETTER_META_KEY keeps the failure details next to the queue the task
+// came from, so it can be requeued exactly where it was.
+
+const (
+	DEAD_LETTER_ZSET_KEY = "task:dead"
+	DEAD_LETTER_META_KEY = "task:dead:meta"
+)
+
+// deadLetterRecord is what DEAD_LETTER_META_KEY stores per task
+type deadLetterRecord struct {
+	Entry  models.DeadLetterEntry `json:"entry"`
+	Origin json.RawMessage        `json:"origin,omitempty"`
+}
+
+// deadLetterScript moves an in-flight task owned by the worker into the
+// dead-letter queue.
+// KEYS: processing list, inflight zset, inflight meta, dead zset, dead meta
+// ARGV: task ID, worker ID, failed at (unix ms), entry JSON
+var deadLetterScript = redis.NewScript(`
+local raw = redis.call('HGET', KEYS[3], ARGV[1])
+if not raw or cjson.decode(raw).worker ~= ARGV[2] then
+	return 0
+end
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('ZADD', KEYS[4], ARGV[3], ARGV[1])
+redis.call('HSET', KEYS[5], ARGV[1], '{"entry":' .. ARGV[4] .. ',"origin":' .. raw .. '}')
+return 1
+`)
+
+// requeueDeadScript takes a task out of the dead-letter queue, stores its
+// reset record and pushes it back into the queue it originally came from.
+// KEYS: dead zset, dead meta, task key
+// ARGV: task ID, task JSON, TTL (ms, 0 for none), default queue
+var requeueDeadScript = withWaitingIndex(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+local raw = redis.call('HGET', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+if ARGV[3] == '0' then
+	redis.call('SET', KEYS[3], ARGV[2])
+else
+	redis.call('SET', KEYS[3], ARGV[2], 'PX', ARGV[3])
+end
+local origin = raw and cjson.decode(raw).origin
+if origin and origin.kind == 'zset' then
+	redis.call('ZADD', origin.queue, origin.score, ARGV[1])
+else
+	redis.call('LPUSH', origin and origin.queue or ARGV[4], ARGV[1])
+end
+mark_waiting(ARGV[1], origin and origin.queue or ARGV[4])
+return 1
+`)
+
+// DeadLetterInFlight moves a failed task the worker holds in-flight into the
+// dead-letter queue. Acking and dead-lettering happen in one step.
+// It returns false if the worker no longer owns the task.
+func DeadLetterInFlight(workerID string, entry models.DeadLetterEntry) (bool, error) {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return false, err
+	}
+
+	keys := []string{processingKey(workerID), INFLIGHT_ZSET_KEY, INFLIGHT_META_KEY, DEAD_LETTER_ZSET_KEY, DEAD_LETTER_META_KEY}
+	return deadLetterScript.Run(ctx, rdb, keys,
+		entry.TaskID, workerID, entry.FailedAt.UnixMilli(), entryJSON).Bool()
+}
+
+// ListDeadLetters returns up to 'limit' dead-letter entries starting at
+// 'offset', oldest failure first
+func ListDeadLetters(offset, limit int64) ([]models.DeadLetterEntry, error) {
+	ids, err := rdb.ZRange(ctx, DEAD_LETTER_ZSET_KEY, offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []models.DeadLetterEntry{}, nil
+	}
+
+	raws, err := rdb.HMGet(ctx, DEAD_LETTER_META_KEY, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.DeadLetterEntry, 0, len(ids))
+	for i, raw := range raws {
+		s, ok := raw.(string)
+		if !ok {
+			// Details are missing, still list the task so it can be requeued or purged
+			entries = append(entries, models.DeadLetterEntry{TaskID: ids[i]})
+			continue
+		}
+		var record deadLetterRecord
+		if err := json.Unmarshal([]byte(s), &record); err != nil {
+			return nil, err
+		}
+		entries = append(entries, record.Entry)
+	}
+	return entries, nil
+}
+
+// GetDeadLetterCount returns the number of tasks in the dead-letter queue
+func GetDeadLetterCount() (int64, error) {
+	return rdb.ZCard(ctx, DEAD_LETTER_ZSET_KEY).Result()
+}
+
+// RequeueDeadLetter resets a dead-lettered task (status, retry count, error)
+// and puts it back into the queue it came from.
+// It returns false if the task is not in the dead-letter queue.
+func RequeueDeadLetter(taskID string) (bool, error) {
+	task, err := GetTask(taskID)
+	if err == redis.Nil {
+		// The record expired, nothing left to requeue
+		_, err := PurgeDeadLetter(taskID)
+		return false, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	task.Status = "queued"
+	task.RetryCount = 0
+	task.StartedAt = nil
+	task.CompletedAt = nil
+	task.Result = ""
+	task.Error = ""
+	taskJSON, err := json.Marshal(task)
+	if err != nil {
+		return false, err
+	}
+
+	keys := []string{DEAD_LETTER_ZSET_KEY, DEAD_LETTER_META_KEY, TASK_RESULT_PREFIX + taskID}
+	return requeueDeadScript.Run(ctx, rdb, keys,
+		taskID, taskJSON, taskTTL(task).Milliseconds(), FIFO_QUEUE_KEY).Bool()
+}
+
+// RequeueAllDeadLetters requeues every task in the dead-letter queue and
+// returns how many were requeued
+func RequeueAllDeadLetters() (int, error) {
+	requeued := 0
+	for {
+		ids, err := rdb.ZRange(ctx, DEAD_LETTER_ZSET_KEY, 0, 127).Result()
+		if err != nil {
+			return requeued, err
+		}
+		if len(ids) == 0 {
+			return requeued, nil
+		}
+		for _, id := range ids {
+			ok, err := RequeueDeadLetter(id)
+			if err != nil {
+				return requeued, err
+			}
+			if ok {
+				requeued++
+			}
+		}
+	}
+}
+
+// PurgeDeadLetter drops a task from the dead-letter queue. The task record
+// itself is kept until its TTL expires so GET /task/:id still works.
+// It returns false if the task was not in the dead-letter queue.
+func PurgeDeadLetter(taskID string) (bool, error) {
+	pipe := rdb.TxPipeline()
+	removed := pipe.ZRem(ctx, DEAD_LETTER_ZSET_KEY, taskID)
+	pipe.HDel(ctx, DEAD_LETTER_META_KEY, taskID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
+
+// PurgeAllDeadLetters empties the dead-letter queue and returns how many
+// tasks it held
+func PurgeAllDeadLetters() (int64, error) {
+	pipe := rdb.TxPipeline()
+	count := pipe.ZCard(ctx, DEAD_LETTER_ZSET_KEY)
+	pipe.Del(ctx, DEAD_LETTER_ZSET_KEY, DEAD_LETTER_META_KEY)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ============================================
+// Idempotency Records
+// ============================================
+//
+// A task submitted with a client-chosen ID is stored together with a record
+// of the request and the response it got. When the ID is reused, the record
+// tells an honest retry (same request, gets the original response replayed)
+// from a client bug (different request, rejected).
+
+const (
+	IDEMPOTENCY_PREFIX = "task:idempotency:"
+	// IDEMPOTENCY_TTL is how long a reused ID is checked against the original request
+	IDEMPOTENCY_TTL = 7 * 24 * time.Hour
+)
+
+// IdempotencyRecord is what a task submission is remembered by
+type IdempotencyRecord struct {
+	Fingerprint string          `json:"fingerprint"` // hash of Request
+	Request     json.RawMessage `json:"request"`     // the request fields a retry must repeat
+	StatusCode  int             `json:"status_code"`
+	Response    json.RawMessage `json:"response"`
+}
+
+// GetIdempotencyRecord retrieves the idempotency record of a task.
+// It returns redis.Nil if the task was created without one or it expired.
+func GetIdempotencyRecord(taskID string) (*IdempotencyRecord, error) {
+	raw, err := rdb.Get(ctx, IDEMPOTENCY_PREFIX+taskID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var record IdempotencyRecord
+	if err := json.Unmarshal([]byte(raw), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
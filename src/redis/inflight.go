@@ -0,0 +1,286 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ============================================
+// In-flight Task Tracking (reliable dequeue)
+// ============================================
+//
+// A reliable dequeue never leaves a task outside of Redis. The task ID is
+// moved from its queue into the worker's processing list in a single script,
+// together with a lease deadline in INFLIGHT_ZSET_KEY and a record of where
+// it came from and who owns it in INFLIGHT_META_KEY. The owner keeps the
+// lease alive by heartbeating and acks the task once it has reached a final
+// state. A reaper claims tasks whose lease has expired and decides whether
+// they go back to their queue or are failed.
+
+const (
+	// PROCESSING_LIST_PREFIX + workerID holds the task IDs a worker is running
+	PROCESSING_LIST_PREFIX = "task:processing:"
+	// INFLIGHT_ZSET_KEY scores every in-flight task ID by its lease deadline (unix ms)
+	INFLIGHT_ZSET_KEY = "task:inflight"
+	// INFLIGHT_META_KEY maps an in-flight task ID to its origin queue and owner
+	INFLIGHT_META_KEY = "task:inflight:meta"
+)
+
+// dequeueListScript moves the oldest task of the first non-empty list queue
+// into the worker's processing list and records its deadline and origin.
+// KEYS: processing list, inflight zset, inflight meta, queue...
+// ARGV: deadline (unix ms), worker ID
+var dequeueListScript = withWaitingIndex(`
+for i = 4, #KEYS do
+	local id = redis.call('LMOVE', KEYS[i], KEYS[1], 'RIGHT', 'LEFT')
+	if id then
+		redis.call('ZADD', KEYS[2], ARGV[1], id)
+		redis.call('HSET', KEYS[3], id, cjson.encode({queue = KEYS[i], kind = 'list', worker = ARGV[2]}))
+		unmark_waiting(id)
+		return id
+	end
+end
+return false
+`)
+
+// dequeueZSetScript moves the lowest scored task of the first non-empty
+// sorted set queue into the worker's processing list, keeping the score so
+// the task can be restored.
+// KEYS: processing list, inflight zset, inflight meta, queue...
+// ARGV: deadline (unix ms), worker ID
+var dequeueZSetScript = withWaitingIndex(`
+for i = 4, #KEYS do
+	local popped = redis.call('ZPOPMIN', KEYS[i])
+	if #popped > 0 then
+		local id = popped[1]
+		redis.call('LPUSH', KEYS[1], id)
+		redis.call('ZADD', KEYS[2], ARGV[1], id)
+		redis.call('HSET', KEYS[3], id, cjson.encode({queue = KEYS[i], kind = 'zset', score = popped[2], worker = ARGV[2]}))
+		unmark_waiting(id)
+		return id
+	end
+end
+return false
+`)
+
+// ackScript forgets an in-flight task, unless its lease has been taken over
+// by someone else in the meantime.
+// KEYS: processing list, inflight zset, inflight meta
+// ARGV: task ID, worker ID
+var ackScript = redis.NewScript(`
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+local raw = redis.call('HGET', KEYS[3], ARGV[1])
+if not raw or cjson.decode(raw).worker ~= ARGV[2] then
+	return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+return 1
+`)
+
+// extendLeaseScript moves the lease deadline of a task the worker still owns.
+// KEYS: inflight zset, inflight meta
+// ARGV: task ID, worker ID, deadline (unix ms)
+var extendLeaseScript = redis.NewScript(`
+local raw = redis.call('HGET', KEYS[2], ARGV[1])
+if not raw or cjson.decode(raw).worker ~= ARGV[2] then
+	return 0
+end
+redis.call('ZADD', KEYS[1], 'XX', ARGV[3], ARGV[1])
+return 1
+`)
+
+// claimExpiredScript transfers up to 'limit' tasks whose lease has passed to
+// the reaper, giving it a fresh lease to decide what happens to them.
+// KEYS: inflight zset, inflight meta, reaper processing list
+// ARGV: now (unix ms), limit, processing list prefix, reaper ID, new deadline (unix ms)
+var claimExpiredScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+local claimed = {}
+for _, id in ipairs(ids) do
+	local raw = redis.call('HGET', KEYS[2], id)
+	if raw then
+		local meta = cjson.decode(raw)
+		redis.call('LREM', ARGV[3] .. meta.worker, 1, id)
+		redis.call('LPUSH', KEYS[3], id)
+		claimed[#claimed + 1] = id
+		claimed[#claimed + 1] = meta.worker
+		meta.worker = ARGV[4]
+		redis.call('HSET', KEYS[2], id, cjson.encode(meta))
+		redis.call('ZADD', KEYS[1], ARGV[5], id)
+	else
+		redis.call('ZREM', KEYS[1], id)
+	end
+end
+return claimed
+`)
+
+// requeueOneScript puts a single in-flight task owned by the worker back at
+// the head of the queue it was taken from.
+// KEYS: processing list, inflight zset, inflight meta
+// ARGV: task ID, worker ID
+var requeueOneScript = withWaitingIndex(`
+local raw = redis.call('HGET', KEYS[3], ARGV[1])
+if not raw then
+	return 0
+end
+local meta = cjson.decode(raw)
+if meta.worker ~= ARGV[2] then
+	return 0
+end
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+if meta.kind == 'zset' then
+	redis.call('ZADD', meta.queue, meta.score, ARGV[1])
+else
+	redis.call('RPUSH', meta.queue, ARGV[1])
+end
+mark_waiting(ARGV[1], meta.queue)
+return 1
+`)
+
+// requeueWorkerScript puts every task in a worker's processing list back at
+// the head of the queue it was taken from.
+// KEYS: processing list, inflight zset, inflight meta
+var requeueWorkerScript = withWaitingIndex(`
+local ids = redis.call('LRANGE', KEYS[1], 0, -1)
+for _, id in ipairs(ids) do
+	local raw = redis.call('HGET', KEYS[3], id)
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('HDEL', KEYS[3], id)
+	if raw then
+		local meta = cjson.decode(raw)
+		if meta.kind == 'zset' then
+			redis.call('ZADD', meta.queue, meta.score, id)
+		else
+			redis.call('RPUSH', meta.queue, id)
+		end
+		mark_waiting(id, meta.queue)
+	end
+end
+redis.call('DEL', KEYS[1])
+return ids
+`)
+
+// inFlightMeta mirrors the JSON the dequeue scripts store in INFLIGHT_META_KEY
+type inFlightMeta struct {
+	Queue  string `json:"queue"`
+	Kind   string `json:"kind"`
+	Score  string `json:"score,omitempty"`
+	Worker string `json:"worker"`
+}
+
+func processingKey(workerID string) string {
+	return PROCESSING_LIST_PREFIX + workerID
+}
+
+func dequeueInFlight(script *redis.Script, workerID string, lease time.Duration, queueKeys ...string) (string, error) {
+	deadline := time.Now().Add(lease).UnixMilli()
+	keys := append([]string{processingKey(workerID), INFLIGHT_ZSET_KEY, INFLIGHT_META_KEY}, queueKeys...)
+	return script.Run(ctx, rdb, keys, deadline, workerID).Text()
+}
+
+// BlockingDequeueFIFO takes the next task ID from the first non-empty list
+// queue in queueKeys, waiting for up to 'timeout' while all of them are
+// empty. The task is moved into the worker's processing list under a lease.
+// It returns redis.Nil when the timeout passes.
+func BlockingDequeueFIFO(workerID string, lease, timeout time.Duration, queueKeys ...string) (string, error) {
+	return blockingDequeue(dequeueListScript, workerID, lease, timeout, queueKeys...)
+}
+
+// BlockingDequeuePriority takes the highest priority task ID from the first
+// non-empty sorted set queue in queueKeys, waiting for up to 'timeout' while
+// all of them are empty. The task is moved into the worker's processing list
+// under a lease. It returns redis.Nil when the timeout passes.
+func BlockingDequeuePriority(workerID string, lease, timeout time.Duration, queueKeys ...string) (string, error) {
+	return blockingDequeue(dequeueZSetScript, workerID, lease, timeout, queueKeys...)
+}
+
+// blockingDequeue takes a task with the atomic dequeue script, and while the
+// queues are empty blocks on their wake-up tokens instead of the queues
+// themselves: a blocking pop would take the task out of its queue before it
+// is tracked, and lose it if the worker died in between. A token left by a
+// push that raced the first attempt wakes the worker right away.
+func blockingDequeue(script *redis.Script, workerID string, lease, timeout time.Duration, queueKeys ...string) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		// Under load the script finds work right away
+		taskID, err := dequeueInFlight(script, workerID, lease, queueKeys...)
+		if err != redis.Nil {
+			return taskID, err
+		}
+
+		wait := time.Until(deadline)
+		if wait < time.Second {
+			// BRPOP counts its timeout in whole seconds
+			return "", redis.Nil
+		}
+		wakeupKeys := make([]string, len(queueKeys))
+		for i, key := range queueKeys {
+			wakeupKeys[i] = WAKEUP_PREFIX + key
+		}
+		if err := rdb.BRPop(ctx, wait, wakeupKeys...).Err(); err != nil {
+			return "", err
+		}
+		// Woken by a push; another worker may have taken the task already
+	}
+}
+
+// AckTask removes a finished task from the worker's processing list so it is
+// never handed out again. It returns false if the worker had already lost
+// the lease to the reaper.
+func AckTask(workerID, taskID string) (bool, error) {
+	keys := []string{processingKey(workerID), INFLIGHT_ZSET_KEY, INFLIGHT_META_KEY}
+	return ackScript.Run(ctx, rdb, keys, taskID, workerID).Bool()
+}
+
+// ExtendLease pushes the lease deadline of an in-flight task to now + lease.
+// It returns false if the worker no longer owns the task.
+func ExtendLease(workerID, taskID string, lease time.Duration) (bool, error) {
+	keys := []string{INFLIGHT_ZSET_KEY, INFLIGHT_META_KEY}
+	deadline := time.Now().Add(lease).UnixMilli()
+	return extendLeaseScript.Run(ctx, rdb, keys, taskID, workerID, deadline).Bool()
+}
+
+// ExpiredLease identifies a task claimed by the reaper and the worker that let its lease run out
+type ExpiredLease struct {
+	TaskID   string
+	WorkerID string
+}
+
+// ClaimExpiredLeases takes over up to 'limit' tasks whose lease has passed.
+// The reaper becomes their owner for 'hold', so if it crashes before acking
+// or requeueing them another reaper picks them up again.
+// The script runs atomically, so any number of reapers can call it.
+func ClaimExpiredLeases(reaperID string, limit int, hold time.Duration) ([]ExpiredLease, error) {
+	keys := []string{INFLIGHT_ZSET_KEY, INFLIGHT_META_KEY, processingKey(reaperID)}
+	now := time.Now()
+	pairs, err := claimExpiredScript.Run(ctx, rdb, keys,
+		now.UnixMilli(), limit, PROCESSING_LIST_PREFIX, reaperID, now.Add(hold).UnixMilli()).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	leases := make([]ExpiredLease, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		leases = append(leases, ExpiredLease{TaskID: pairs[i], WorkerID: pairs[i+1]})
+	}
+	return leases, nil
+}
+
+// RequeueInFlight puts a task owned by the worker back at the head of the
+// queue it was dequeued from
+func RequeueInFlight(workerID, taskID string) error {
+	keys := []string{processingKey(workerID), INFLIGHT_ZSET_KEY, INFLIGHT_META_KEY}
+	return requeueOneScript.Run(ctx, rdb, keys, taskID, workerID).Err()
+}
+
+// RequeueWorkerInFlight returns every task still held by the given worker to
+// its original queue. A restarted worker calls this to recover its own tasks
+// without waiting for their deadlines.
+func RequeueWorkerInFlight(workerID string) ([]string, error) {
+	keys := []string{processingKey(workerID), INFLIGHT_ZSET_KEY, INFLIGHT_META_KEY}
+	return requeueWorkerScript.Run(ctx, rdb, keys).StringSlice()
+}
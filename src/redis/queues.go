@@ -0,0 +1,149 @@
+package redis
+
+import (
+	"regexp"
+	"sort"
+
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// ============================================
+// Named Queues
+// ============================================
+//
+// Besides the built-in "fifo" and "priority" queues, tasks can be submitted
+// to any named queue. Named queues are FIFO lists under NAMED_QUEUE_PREFIX,
+// so they share the list dequeue, in-flight and retry paths with the FIFO
+// queue. QUEUE_REGISTRY_KEY remembers every named queue that ever received
+// a task so they can be listed.
+//
+// Any queue can be paused: workers skip the queues in PAUSED_QUEUES_KEY when
+// dequeuing, while submissions to them are still accepted.
+
+const (
+	// FIFO_QUEUE_NAME and PRIORITY_QUEUE_NAME are the names of the built-in queues
+	FIFO_QUEUE_NAME     = "fifo"
+	PRIORITY_QUEUE_NAME = "priority"
+	NAMED_QUEUE_PREFIX  = "task:queue:"
+	QUEUE_REGISTRY_KEY  = "task:queues"
+	PAUSED_QUEUES_KEY   = "task:queues:paused"
+)
+
+var queueNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidQueueName reports whether name can be used as a queue name
+func ValidQueueName(name string) bool {
+	return queueNamePattern.MatchString(name)
+}
+
+// QueueKey returns the Redis key of a queue by name
+func QueueKey(name string) string {
+	switch name {
+	case FIFO_QUEUE_NAME:
+		return FIFO_QUEUE_KEY
+	case PRIORITY_QUEUE_NAME:
+		return PRIORITY_QUEUE_KEY
+	default:
+		return NAMED_QUEUE_PREFIX + name
+	}
+}
+
+// listQueueKey returns the list a task waits in: its named queue, or the
+// FIFO queue for built-in queues and tasks stored before named queues existed
+func listQueueKey(task *models.Task) string {
+	if task.Queue == "" || task.Queue == PRIORITY_QUEUE_NAME {
+		return FIFO_QUEUE_KEY
+	}
+	return QueueKey(task.Queue)
+}
+
+// CreateTaskInQueue atomically stores a new task and enqueues it to the
+// queue it is addressed to (task.Queue), or schedules it for later if
+// task.ScheduledAt is set.
+// It returns "" if the task was created. If a task with the same ID, or a
+// unique task with the same unique key within its window, already exists it
+// returns that task's ID without touching anything.
+// record, if not nil, is stored as the task's idempotency record.
+func CreateTaskInQueue(task *models.Task, record *IdempotencyRecord) (string, error) {
+	if task.Queue != FIFO_QUEUE_NAME && task.Queue != PRIORITY_QUEUE_NAME {
+		if err := rdb.SAdd(ctx, QUEUE_REGISTRY_KEY, task.Queue).Err(); err != nil {
+			return "", err
+		}
+	}
+	if task.Queue == PRIORITY_QUEUE_NAME {
+		return createTask(task, PRIORITY_QUEUE_KEY, "zset", taskScore(task), record)
+	}
+	return createTask(task, QueueKey(task.Queue), "list", 0, record)
+}
+
+// ListQueues returns the names of all queues, built-in queues first and
+// named queues in alphabetical order
+func ListQueues() ([]string, error) {
+	named, err := rdb.SMembers(ctx, QUEUE_REGISTRY_KEY).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(named)
+	return append([]string{FIFO_QUEUE_NAME, PRIORITY_QUEUE_NAME}, named...), nil
+}
+
+// GetQueueLength returns the number of tasks waiting in a queue by name
+func GetQueueLength(name string) (int64, error) {
+	if name == PRIORITY_QUEUE_NAME {
+		return GetPriorityQueueLength()
+	}
+	return rdb.LLen(ctx, QueueKey(name)).Result()
+}
+
+// PauseQueue stops workers from dequeuing from a queue by name. Tasks
+// submitted to it wait until it is resumed. It returns false if the queue
+// was already paused.
+func PauseQueue(name string) (bool, error) {
+	if name != FIFO_QUEUE_NAME && name != PRIORITY_QUEUE_NAME {
+		// List the queue even if nothing was submitted to it yet
+		if err := rdb.SAdd(ctx, QUEUE_REGISTRY_KEY, name).Err(); err != nil {
+			return false, err
+		}
+	}
+	added, err := rdb.SAdd(ctx, PAUSED_QUEUES_KEY, name).Result()
+	return added == 1, err
+}
+
+// ResumeQueue lets workers dequeue from a paused queue again. It returns
+// false if the queue was not paused.
+func ResumeQueue(name string) (bool, error) {
+	removed, err := rdb.SRem(ctx, PAUSED_QUEUES_KEY, name).Result()
+	return removed == 1, err
+}
+
+// PausedQueues returns the names of the paused queues
+func PausedQueues() (map[string]bool, error) {
+	names, err := rdb.SMembers(ctx, PAUSED_QUEUES_KEY).Result()
+	if err != nil {
+		return nil, err
+	}
+	paused := make(map[string]bool, len(names))
+	for _, name := range names {
+		paused[name] = true
+	}
+	return paused, nil
+}
+
+// IsQueuePaused reports whether a queue by name is paused
+func IsQueuePaused(name string) (bool, error) {
+	return rdb.SIsMember(ctx, PAUSED_QUEUES_KEY, name).Result()
+}
+
+// clearNamedQueues removes every named queue, the queue registry and the
+// paused flags
+func clearNamedQueues() error {
+	named, err := rdb.SMembers(ctx, QUEUE_REGISTRY_KEY).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{QUEUE_REGISTRY_KEY, PAUSED_QUEUES_KEY}
+	for _, name := range named {
+		keys = append(keys, QueueKey(name))
+	}
+	return rdb.Del(ctx, keys...).Err()
+}
@@ -3,9 +3,9 @@ package redis
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -17,15 +17,35 @@ var (
 	ctx = context.Background()
 )
 
+// Nil is returned when a requested key does not exist (re-exported from go-redis)
+const Nil = redis.Nil
+
 const (
 	FIFO_QUEUE_KEY     = "task:queue"
 	PRIORITY_QUEUE_KEY = "task:priority_queue"
 	TASK_RESULT_PREFIX = "task:result:"
 	RETRY_ZSET_KEY     = "task:retry"
-	// TASK_TTL is the expiration time for task storage (7 days)
+	// RETRY_META_KEY maps a scheduled retry to the queue it is promoted into
+	RETRY_META_KEY = "task:retry:meta"
+	// SCHEDULED_ZSET_KEY holds delayed tasks scored by their run time (unix ms)
+	SCHEDULED_ZSET_KEY = "task:scheduled"
+	// SCHEDULED_META_KEY maps a delayed task to the queue it is promoted into
+	SCHEDULED_META_KEY = "task:scheduled:meta"
+	// TASK_TTL is how long workflow records and, by default, failed tasks
+	// are kept (7 days); see retention for task records
 	TASK_TTL = 7 * 24 * time.Hour
 )
 
+// poolSize is the number of connections the client keeps, 0 for the go-redis
+// default of 10 per CPU (see SetPoolSize)
+var poolSize int
+
+// SetPoolSize sets how many connections InitRedis opens the client with.
+// Every blocking dequeue in progress holds one of them.
+func SetPoolSize(n int) {
+	poolSize = n
+}
+
 func InitRedis() {
 	// Get Redis address from environment variable, default to localhost
 	redisAddr := os.Getenv("REDIS_ADDR")
@@ -38,6 +58,7 @@ func InitRedis() {
 		Addr:     redisAddr, // Use environment variable
 		Password: "",
 		DB:       0,
+		PoolSize: poolSize,
 	})
 
 	// Test Redis connection
@@ -46,6 +67,9 @@ func InitRedis() {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
 	log.Println("✓ Connected to Redis")
+
+	loadAgingPolicy()
+	loadRetentionPolicy()
 }
 
 // CloseRedis closes the Redis client connection
@@ -60,16 +84,6 @@ func CloseRedis() {
 // FIFO Queue Operations
 // ============================================
 
-// EnqueueFIFO adds a task ID to the FIFO queue
-func EnqueueFIFO(taskID string) error {
-	return rdb.LPush(ctx, FIFO_QUEUE_KEY, taskID).Err()
-}
-
-// DequeueFIFO removes and returns a task ID from the FIFO queue
-func DequeueFIFO() (string, error) {
-	return rdb.RPop(ctx, FIFO_QUEUE_KEY).Result()
-}
-
 // GetFIFOQueueLength returns the number of tasks in the FIFO queue
 func GetFIFOQueueLength() (int64, error) {
 	return rdb.LLen(ctx, FIFO_QUEUE_KEY).Result()
@@ -84,28 +98,108 @@ func ClearFIFOQueue() error {
 // Priority Queue Operations
 // ============================================
 
-// EnqueuePriority adds a task ID to the priority queue
-// Short jobs get score 1.0 (higher priority)
-// Long jobs get score 2.0 (lower priority)
-func EnqueuePriority(taskID string, jobType string) error {
-	score := 2.0 // Default: long jobs (lower priority)
+// MAX_PRIORITY is the highest task priority; 0 is the lowest
+const MAX_PRIORITY = 255
+
+// priorityTimeBits is how many low bits of a score hold the enqueue time in
+// unix ms; 2^44 ms lasts until the year 2527 and, with 8 bits of priority on
+// top, the score stays an exact integer in a float64
+const priorityTimeBits = 44
+
+// Aging policy for the priority queue, see loadAgingPolicy.
+// With agingStep 0 priorities are strict and a steady stream of high
+// priority tasks can starve lower ones.
+var (
+	agingStep    = 5 * time.Second
+	agingMaxWait = 60 * time.Second
+)
+
+// DefaultPriority returns the priority of tasks submitted without one.
+// Short jobs rank above long jobs (shortest job first).
+func DefaultPriority(jobType string) int {
 	if jobType == "short" {
-		score = 1.0 // Short jobs (higher priority)
+		return 2
+	}
+	return 1
+}
+
+// priorityScore encodes a priority and enqueue time into one ZSET score so
+// that ZPOPMIN returns the highest priority first and, within a priority,
+// the task enqueued first
+func priorityScore(priority int, enqueuedAt time.Time) float64 {
+	if priority < 0 {
+		priority = 0
+	}
+	if priority > MAX_PRIORITY {
+		priority = MAX_PRIORITY
+	}
+	rank := int64(MAX_PRIORITY - priority)
+	if agingStep <= 0 {
+		return float64(rank<<priorityTimeBits | enqueuedAt.UnixMilli())
 	}
 
-	return rdb.ZAdd(ctx, PRIORITY_QUEUE_KEY, &redis.Z{
-		Score:  score,
-		Member: taskID,
-	}).Err()
+	// The score is the time at which the task catches up with the highest
+	// priority
+	return float64(enqueuedAt.Add(agingHandicap(priority)).UnixMilli())
 }
 
-// DequeuePriority removes and returns the highest priority task ID
-func DequeuePriority() (string, error) {
-	result := rdb.ZPopMin(ctx, PRIORITY_QUEUE_KEY, 1).Val()
-	if len(result) == 0 {
-		return "", redis.Nil
+// agingHandicap returns how long a task of the given priority waits behind
+// tasks of the highest priority enqueued after it. Every level is worth
+// agingStep of waiting. When all levels don't fit in agingMaxWait, the
+// lowest ones (where the default priorities sit) keep the full step until
+// half of it is used and the levels above share the other half evenly, so
+// every level stays distinct and priority 0 never waits longer than
+// agingMaxWait.
+func agingHandicap(priority int) time.Duration {
+	rank := time.Duration(MAX_PRIORITY - priority)
+	if agingMaxWait <= 0 || MAX_PRIORITY*agingStep <= agingMaxWait {
+		return rank * agingStep
 	}
-	return result[0].Member.(string), nil
+
+	// How far the level is ahead of priority 0
+	full := int(agingMaxWait / 2 / agingStep)
+	lead := time.Duration(min(priority, full)) * agingStep
+	if priority > full {
+		rest := agingMaxWait - time.Duration(full)*agingStep
+		lead += rest * time.Duration(priority-full) / time.Duration(MAX_PRIORITY-full)
+	}
+	return agingMaxWait - lead
+}
+
+// taskScore returns the priority score of a new task. A delayed task is
+// scored from its planned run time, so it doesn't age while it isn't due.
+func taskScore(task *models.Task) float64 {
+	if task.ScheduledAt != nil {
+		return priorityScore(task.Priority, *task.ScheduledAt)
+	}
+	return priorityScore(task.Priority, task.SubmittedAt)
+}
+
+// loadAgingPolicy reads the priority aging policy from the environment.
+// PRIORITY_AGING_STEP is the wait that earns a task one priority level
+// ("0" disables aging), PRIORITY_MAX_WAIT caps how long any task waits
+// behind tasks enqueued after it ("0" for no cap), see agingHandicap.
+func loadAgingPolicy() {
+	if v := os.Getenv("PRIORITY_AGING_STEP"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid PRIORITY_AGING_STEP %q: %v", v, err)
+		}
+		agingStep = d
+	}
+	if v := os.Getenv("PRIORITY_MAX_WAIT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid PRIORITY_MAX_WAIT %q: %v", v, err)
+		}
+		agingMaxWait = d
+	}
+
+	if agingStep <= 0 {
+		log.Println("✓ Priority aging disabled (strict priorities)")
+		return
+	}
+	log.Printf("✓ Priority aging: one level per %v, max wait %v", agingStep, agingMaxWait)
 }
 
 // GetPriorityQueueLength returns the number of tasks in the priority queue
@@ -122,7 +216,8 @@ func ClearPriorityQueue() error {
 // Task Storage Operations (Redis STRING)
 // ============================================
 
-// StoreTask stores a task in Redis as a JSON string with TTL
+// StoreTask stores a task in Redis as a JSON string, with the retention of
+// its status as TTL
 func StoreTask(task *models.Task) error {
 	taskJSON, err := json.Marshal(task)
 	if err != nil {
@@ -130,7 +225,43 @@ func StoreTask(task *models.Task) error {
 	}
 
 	key := TASK_RESULT_PREFIX + task.ID
-	return rdb.Set(ctx, key, taskJSON, TASK_TTL).Err()
+	return rdb.Set(ctx, key, taskJSON, taskTTL(task)).Err()
+}
+
+// storeProgressScript replaces the progress of a stored task, as long as it
+// is still running and the worker owns its lease. The rest of the JSON
+// written by Go is kept as is; progress is its last field.
+// KEYS: task key, inflight meta
+// ARGV: task ID, worker ID, progress JSON
+var storeProgressScript = redis.NewScript(`
+local raw = redis.call('HGET', KEYS[2], ARGV[1])
+if not raw or cjson.decode(raw).worker ~= ARGV[2] then
+	return 0
+end
+local task = redis.call('GET', KEYS[1])
+if not task or not string.find(task, '"status":"running"', 1, true) then
+	return 0
+end
+local cut = string.find(task, ',"progress":', 1, true)
+if cut then
+	task = string.sub(task, 1, cut - 1)
+else
+	task = string.sub(task, 1, -2)
+end
+redis.call('SET', KEYS[1], task .. ',"progress":' .. ARGV[3] .. '}', 'KEEPTTL')
+return 1
+`)
+
+// StoreProgress stores the progress of a running task without touching the
+// rest of it. It returns false if the task is no longer running or the
+// worker has lost its lease, so a late update never overwrites an outcome.
+func StoreProgress(workerID, taskID string, progress models.TaskProgress) (bool, error) {
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		return false, err
+	}
+	keys := []string{TASK_RESULT_PREFIX + taskID, INFLIGHT_META_KEY}
+	return storeProgressScript.Run(ctx, rdb, keys, taskID, workerID, progressJSON).Bool()
 }
 
 // GetTask retrieves a task from Redis by ID
@@ -150,14 +281,103 @@ func GetTask(taskID string) (*models.Task, error) {
 	return &task, nil
 }
 
-// TaskExists checks if a task exists in Redis (for idempotency)
-func TaskExists(taskID string) (bool, error) {
-	key := TASK_RESULT_PREFIX + taskID
-	exists, err := rdb.Exists(ctx, key).Result()
+// createTaskScript stores a task only if its ID is new and enqueues it in the
+// same step, so a task is never stored without being queued or queued twice.
+// Delayed tasks are parked in the scheduled ZSET instead, together with the
+// queue they are promoted into. Unique tasks first check and then take the
+// unique lock; a lock whose task record is gone no longer counts. Returns an
+// empty string if the task was created, otherwise the ID of the existing task. The
+// idempotency record, if any, is stored together with the task. It outlives
+// tasks with a shorter retention, so an ID whose record still exists counts
+// as used even after its task expired.
+// KEYS: task key, queue key, scheduled zset, scheduled meta, unique lock,
+// idempotency record
+// ARGV: task ID, task JSON, TTL (ms, 0 for none), queue kind ("list" or "zset"),
+// score, run at (unix ms, empty to enqueue now), queue meta JSON,
+// unique window (ms, 0 if the task is not unique), task key prefix,
+// idempotency record JSON (empty for none), idempotency record TTL (ms)
+var createTaskScript = withWaitingIndex(`
+if redis.call('EXISTS', KEYS[6]) == 1 then
+	return ARGV[1]
+end
+if ARGV[8] ~= '0' then
+	local holder = redis.call('GET', KEYS[5])
+	if holder and redis.call('EXISTS', ARGV[9] .. holder) == 1 then
+		return holder
+	end
+end
+local set = {'SET', KEYS[1], ARGV[2], 'NX'}
+if ARGV[3] ~= '0' then
+	table.insert(set, 'PX')
+	table.insert(set, ARGV[3])
+end
+if not redis.call(unpack(set)) then
+	return ARGV[1]
+end
+if ARGV[8] ~= '0' then
+	redis.call('SET', KEYS[5], ARGV[1], 'PX', ARGV[8])
+end
+if ARGV[10] ~= '' then
+	redis.call('SET', KEYS[6], ARGV[10], 'PX', ARGV[11])
+end
+if ARGV[6] ~= '' then
+	redis.call('ZADD', KEYS[3], ARGV[6], ARGV[1])
+	redis.call('HSET', KEYS[4], ARGV[1], ARGV[7])
+elseif ARGV[4] == 'zset' then
+	redis.call('ZADD', KEYS[2], ARGV[5], ARGV[1])
+	mark_waiting(ARGV[1], KEYS[2])
+else
+	redis.call('LPUSH', KEYS[2], ARGV[1])
+	mark_waiting(ARGV[1], KEYS[2])
+end
+return ''
+`)
+
+func createTask(task *models.Task, queueKey, kind string, score float64, record *IdempotencyRecord) (string, error) {
+	keys, args, err := createTaskArgs(task, queueKey, kind, score, record)
 	if err != nil {
-		return false, err
+		return "", err
 	}
-	return exists > 0, nil
+	return createTaskScript.Run(ctx, rdb, keys, args...).Text()
+}
+
+// createTaskArgs returns the keys and arguments of createTaskScript for a task
+func createTaskArgs(task *models.Task, queueKey, kind string, score float64, record *IdempotencyRecord) ([]string, []interface{}, error) {
+	taskJSON, err := json.Marshal(task)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	runAt := ""
+	if task.ScheduledAt != nil {
+		runAt = strconv.FormatInt(task.ScheduledAt.UnixMilli(), 10)
+	}
+	metaJSON, err := json.Marshal(inFlightMeta{
+		Queue: queueKey,
+		Kind:  kind,
+		Score: strconv.FormatFloat(score, 'f', -1, 64),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uniqueFor := int64(0)
+	if task.UniqueKey != "" {
+		uniqueFor = (time.Duration(task.UniqueFor) * time.Second).Milliseconds()
+	}
+
+	recordJSON := []byte{}
+	if record != nil {
+		if recordJSON, err = json.Marshal(record); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	keys := []string{TASK_RESULT_PREFIX + task.ID, queueKey, SCHEDULED_ZSET_KEY, SCHEDULED_META_KEY,
+		UNIQUE_LOCK_PREFIX + task.UniqueKey, IDEMPOTENCY_PREFIX + task.ID}
+	args := []interface{}{task.ID, taskJSON, taskTTL(task).Milliseconds(), kind, score, runAt, metaJSON, uniqueFor, TASK_RESULT_PREFIX,
+		recordJSON, IDEMPOTENCY_TTL.Milliseconds()}
+	return keys, args, nil
 }
 
 // DeleteTask removes a task from Redis
@@ -199,6 +419,54 @@ func ClearAllData() error {
 		return err
 	}
 
+	// Clear in-flight bookkeeping and every worker's processing list
+	processing, err := rdb.Keys(ctx, PROCESSING_LIST_PREFIX+"*").Result()
+	if err != nil {
+		return err
+	}
+	processing = append(processing, INFLIGHT_ZSET_KEY, INFLIGHT_META_KEY)
+
+	// Clear the waiting index and wake-up tokens
+	waiting, err := rdb.Keys(ctx, WAITING_INDEX_PREFIX+"*").Result()
+	if err != nil {
+		return err
+	}
+	wakeups, err := rdb.Keys(ctx, WAKEUP_PREFIX+"*").Result()
+	if err != nil {
+		return err
+	}
+	processing = append(processing, waiting...)
+	processing = append(processing, wakeups...)
+	if err := rdb.Del(ctx, processing...).Err(); err != nil {
+		return err
+	}
+
+	// Clear unique task locks and idempotency records
+	locks, err := rdb.Keys(ctx, UNIQUE_LOCK_PREFIX+"*").Result()
+	if err != nil {
+		return err
+	}
+	records, err := rdb.Keys(ctx, IDEMPOTENCY_PREFIX+"*").Result()
+	if err != nil {
+		return err
+	}
+	locks = append(locks, records...)
+	if len(locks) > 0 {
+		if err := rdb.Del(ctx, locks...).Err(); err != nil {
+			return err
+		}
+	}
+
+	// Clear named queues
+	if err := clearNamedQueues(); err != nil {
+		return err
+	}
+
+	// Clear workflows and dependency bookkeeping
+	if err := clearWorkflows(); err != nil {
+		return err
+	}
+
 	// Clear all tasks
 	keys, err := GetAllTaskKeys()
 	if err != nil {
@@ -221,60 +489,79 @@ func GetRedisClient() *redis.Client {
 // Retry Queue Operations (for Experiment 3)
 // ============================================
 
-// ScheduleRetry adds a task ID to the retry ZSET with the next retry timestamp as score.
-func ScheduleRetry(taskID string, next time.Time) error {
-	return rdb.ZAdd(ctx, RETRY_ZSET_KEY, &redis.Z{
-		Score:  float64(next.Unix()),
-		Member: taskID,
-	}).Err()
+// scheduleRetryScript hands an in-flight task owned by the worker over to the
+// retry ZSET, carrying along the queue it was dequeued from.
+// KEYS: processing list, inflight zset, inflight meta, retry zset, retry meta
+// ARGV: task ID, worker ID, next retry time (unix ms)
+var scheduleRetryScript = redis.NewScript(`
+local raw = redis.call('HGET', KEYS[3], ARGV[1])
+if not raw or cjson.decode(raw).worker ~= ARGV[2] then
+	return 0
+end
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('ZADD', KEYS[4], ARGV[3], ARGV[1])
+redis.call('HSET', KEYS[5], ARGV[1], raw)
+return 1
+`)
+
+// promoteDueScript moves up to 'limit' due task IDs out of a schedule ZSET
+// into the queue recorded for them, falling back to the default list queue.
+// If a status pair is given, the stored task's status is switched as well
+// (plain text replacement, so the JSON written by Go is kept intact).
+// KEYS: schedule zset, schedule meta
+// ARGV: now (unix ms), limit, default queue, task key prefix,
+// old status JSON, new status JSON (last three may be empty)
+var promoteDueScript = withWaitingIndex(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, id in ipairs(ids) do
+	local raw = redis.call('HGET', KEYS[2], id)
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('HDEL', KEYS[2], id)
+	if ARGV[4] ~= '' then
+		local task = redis.call('GET', ARGV[4] .. id)
+		if task then
+			local updated = string.gsub(task, ARGV[5], ARGV[6], 1)
+			redis.call('SET', ARGV[4] .. id, updated, 'KEEPTTL')
+		end
+	end
+	local meta = raw and cjson.decode(raw) or {queue = ARGV[3], kind = 'list'}
+	if meta.kind == 'zset' then
+		redis.call('ZADD', meta.queue, meta.score, id)
+	else
+		redis.call('LPUSH', meta.queue, id)
+	end
+	mark_waiting(id, meta.queue)
+end
+return ids
+`)
+
+// ScheduleRetry moves a task the worker holds in-flight into the retry ZSET
+// with the next retry timestamp as score. Acking the task and scheduling the
+// retry happen in one step, so the task can't end up in both places.
+// It returns false if the worker no longer owns the task.
+func ScheduleRetry(workerID, taskID string, next time.Time) (bool, error) {
+	keys := []string{processingKey(workerID), INFLIGHT_ZSET_KEY, INFLIGHT_META_KEY, RETRY_ZSET_KEY, RETRY_META_KEY}
+	return scheduleRetryScript.Run(ctx, rdb, keys, taskID, workerID, next.UnixMilli()).Bool()
 }
 
-// PopDueRetries pops up to 'limit' task IDs whose scheduled retry time is <= now.
-// It removes them from the retry ZSET and returns their IDs.
-func PopDueRetries(limit int) ([]string, error) {
-	now := float64(time.Now().Unix())
-
-	items, err := rdb.ZRangeByScore(ctx, RETRY_ZSET_KEY, &redis.ZRangeBy{
-		Min:   "-inf",
-		Max:   fmt.Sprintf("%f", now),
-		Count: int64(limit),
-	}).Result()
-	if err != nil {
-		return nil, err
-	}
-	if len(items) == 0 {
-		return nil, nil
-	}
-
-	members := make([]interface{}, len(items))
-	for i, it := range items {
-		members[i] = it
-	}
-	if err := rdb.ZRem(ctx, RETRY_ZSET_KEY, members...).Err(); err != nil {
-		return nil, err
-	}
-
-	return items, nil
+// PromoteDueRetries moves up to 'limit' task IDs whose scheduled retry time
+// is <= now back into the queue they came from and returns their IDs.
+// The script runs atomically, so any number of schedulers can call it
+// without promoting the same task twice.
+func PromoteDueRetries(limit int) ([]string, error) {
+	keys := []string{RETRY_ZSET_KEY, RETRY_META_KEY}
+	return promoteDueScript.Run(ctx, rdb, keys,
+		time.Now().UnixMilli(), limit, FIFO_QUEUE_KEY, "", "", "").StringSlice()
 }
 
-// ReenqueueByType re-enqueues a task into the appropriate queue based on its JobType.
-// For SJF experiment we push back into the priority queue.
-
-func ReenqueueByType(taskID string) error {
-	task, err := GetTask(taskID)
-	if err != nil {
-		return err
-	}
-	if task == nil {
-		return fmt.Errorf("task %s not found", taskID)
-	}
-
-	switch task.JobType {
-	case "short":
-		return EnqueuePriority(taskID, "short")
-	case "long":
-		return EnqueuePriority(taskID, "long")
-	default:
-		return EnqueueFIFO(taskID)
-	}
+// PromoteDueScheduled moves up to 'limit' delayed tasks whose run time is
+// <= now into their queue, switches them from "scheduled" to "queued" and
+// returns their IDs. Safe to run from any number of processes.
+func PromoteDueScheduled(limit int) ([]string, error) {
+	keys := []string{SCHEDULED_ZSET_KEY, SCHEDULED_META_KEY}
+	return promoteDueScript.Run(ctx, rdb, keys,
+		time.Now().UnixMilli(), limit, FIFO_QUEUE_KEY,
+		TASK_RESULT_PREFIX, `"status":"scheduled"`, `"status":"queued"`).StringSlice()
 }
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// withAging runs f under an aging policy and restores the previous one
+func withAging(t *testing.T, step, maxWait time.Duration, f func()) {
+	t.Helper()
+	prevStep, prevMaxWait := agingStep, agingMaxWait
+	agingStep, agingMaxWait = step, maxWait
+	defer func() { agingStep, agingMaxWait = prevStep, prevMaxWait }()
+	f()
+}
+
+func TestPriorityScoreOrdersPriorities(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+	for _, policy := range []struct {
+		name          string
+		step, maxWait time.Duration
+	}{
+		{"default", 5 * time.Second, 60 * time.Second},
+		{"uncapped", 5 * time.Second, 0},
+		{"strict", 0, 0},
+	} {
+		withAging(t, policy.step, policy.maxWait, func() {
+			// Enqueued at the same time, every level is served before the one below
+			for priority := 1; priority <= MAX_PRIORITY; priority++ {
+				higher := priorityScore(priority, now)
+				lower := priorityScore(priority-1, now)
+				if higher >= lower {
+					t.Errorf("%s: priority %d scores %v, not below priority %d at %v",
+						policy.name, priority, higher, priority-1, lower)
+				}
+			}
+
+			// Within a priority, the task enqueued first is served first
+			if priorityScore(2, now) >= priorityScore(2, now.Add(time.Millisecond)) {
+				t.Errorf("%s: equal priorities are not served in enqueue order", policy.name)
+			}
+		})
+	}
+}
+
+func TestPriorityScoreShortBeforeLong(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+	step := 5 * time.Second
+	withAging(t, step, 60*time.Second, func() {
+		// A long job enqueued a few seconds earlier still loses to a short job
+		long := priorityScore(DefaultPriority("long"), now.Add(-3*time.Second))
+		short := priorityScore(DefaultPriority("short"), now)
+		if short >= long {
+			t.Errorf("short job scores %v, not below long job enqueued 3s earlier at %v", short, long)
+		}
+
+		// but not one enqueued more than a step earlier
+		long = priorityScore(DefaultPriority("long"), now.Add(-step-time.Millisecond))
+		if long >= short {
+			t.Errorf("long job enqueued %v earlier scores %v, not below short job at %v", step, long, short)
+		}
+	})
+}
+
+func TestPriorityScoreBoundsWait(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+	maxWait := 60 * time.Second
+	withAging(t, 5*time.Second, maxWait, func() {
+		for priority := 0; priority <= MAX_PRIORITY; priority++ {
+			handicap := time.Duration(priorityScore(priority, now)-float64(now.UnixMilli())) * time.Millisecond
+			if handicap < 0 || handicap > maxWait {
+				t.Errorf("priority %d is handicapped %v, outside [0, %v]", priority, handicap, maxWait)
+			}
+		}
+
+		// The lowest priority is served before the highest once it has
+		// waited longer than the max wait
+		waited := priorityScore(0, now)
+		fresh := priorityScore(MAX_PRIORITY, now.Add(maxWait+time.Millisecond))
+		if waited >= fresh {
+			t.Errorf("priority 0 after %v scores %v, not below a new priority %d at %v",
+				maxWait, waited, MAX_PRIORITY, fresh)
+		}
+	})
+}
+
+func TestPriorityScoreClampsRange(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+	if priorityScore(-5, now) != priorityScore(0, now) {
+		t.Error("negative priority is not clamped to 0")
+	}
+	if priorityScore(MAX_PRIORITY+10, now) != priorityScore(MAX_PRIORITY, now) {
+		t.Errorf("priority above %d is not clamped", MAX_PRIORITY)
+	}
+}
+
+func TestTaskScoreAgesDelayedTasksFromRunTime(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+	runAt := now.Add(time.Hour)
+	withAging(t, 5*time.Second, 60*time.Second, func() {
+		// A low priority task delayed by an hour must not jump ahead of
+		// a high priority task submitted when it becomes due
+		delayed := taskScore(&models.Task{Priority: 0, SubmittedAt: now, ScheduledAt: &runAt})
+		fresh := taskScore(&models.Task{Priority: MAX_PRIORITY, SubmittedAt: runAt})
+		if delayed <= fresh {
+			t.Errorf("delayed task scores %v, not above a task submitted at its run time at %v", delayed, fresh)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"log"
+	"os"
+	"time"
+
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// ============================================
+// Task Retention
+// ============================================
+//
+// How long a task record is kept depends on its status and counts from the
+// last time the task was stored. A retention of 0 keeps the record until it
+// is deleted, which is the default for active tasks, so a task never loses
+// its record just because it waited long in a queue. Finished tasks can
+// override the retention of their status with result_ttl.
+
+// retention per status group, see retentionGroup (overridable from the
+// environment, see loadRetentionPolicy)
+var retention = map[string]time.Duration{
+	"queued":  0,
+	"running": 0,
+	"success": 24 * time.Hour,
+	"failed":  TASK_TTL,
+}
+
+// retentionGroup maps a task status to the retention setting it uses
+func retentionGroup(status string) string {
+	switch status {
+	case "pending", "scheduled", "queued":
+		return "queued"
+	case "running":
+		return "running"
+	case "success":
+		return "success"
+	default:
+		// failed, cancelled and skipped
+		return "failed"
+	}
+}
+
+// taskTTL returns how long a task record is kept after it is stored,
+// 0 meaning forever
+func taskTTL(task *models.Task) time.Duration {
+	group := retentionGroup(task.Status)
+	if task.ResultTTL > 0 && (group == "success" || group == "failed") {
+		return time.Duration(task.ResultTTL) * time.Second
+	}
+	return retention[group]
+}
+
+// loadRetentionPolicy reads the retention per status from RETENTION_QUEUED,
+// RETENTION_RUNNING, RETENTION_SUCCESS and RETENTION_FAILED, e.g. "48h"
+// ("0" keeps records forever)
+func loadRetentionPolicy() {
+	for group, env := range map[string]string{
+		"queued":  "RETENTION_QUEUED",
+		"running": "RETENTION_RUNNING",
+		"success": "RETENTION_SUCCESS",
+		"failed":  "RETENTION_FAILED",
+	} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid %s %q: must be a non-negative duration", env, v)
+		}
+		retention[group] = d
+	}
+
+	log.Printf("✓ Task retention: queued=%v running=%v success=%v failed=%v (0 = forever)",
+		retention["queued"], retention["running"], retention["success"], retention["failed"])
+}
@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// ============================================
+// Recurring Schedule Operations
+// ============================================
+
+const (
+	// SCHEDULE_DEFS_KEY maps a schedule ID to its JSON definition
+	SCHEDULE_DEFS_KEY = "schedule:defs"
+	// SCHEDULE_NEXT_KEY scores every schedule ID by its next tick (unix ms)
+	SCHEDULE_NEXT_KEY = "schedule:next"
+)
+
+// ScheduleTick is a schedule whose next tick is due
+type ScheduleTick struct {
+	ScheduleID string
+	At         time.Time
+}
+
+// advanceScheduleScript moves a schedule to its next tick, but only if no one
+// else has advanced it past 'tick' yet and it has not been deleted.
+// KEYS: schedule defs, schedule next
+// ARGV: schedule ID, expected tick (unix ms), next tick (unix ms), schedule JSON
+var advanceScheduleScript = redis.NewScript(`
+local current = redis.call('ZSCORE', KEYS[2], ARGV[1])
+if not current or tonumber(current) ~= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[4])
+return 1
+`)
+
+// SaveSchedule stores a schedule definition and its next tick
+func SaveSchedule(schedule *models.Schedule) error {
+	scheduleJSON, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+
+	pipe := rdb.TxPipeline()
+	pipe.HSet(ctx, SCHEDULE_DEFS_KEY, schedule.ID, scheduleJSON)
+	pipe.ZAdd(ctx, SCHEDULE_NEXT_KEY, &redis.Z{
+		Score:  float64(schedule.NextRunAt.UnixMilli()),
+		Member: schedule.ID,
+	})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetSchedule retrieves a schedule by ID, or redis.Nil if it does not exist
+func GetSchedule(scheduleID string) (*models.Schedule, error) {
+	scheduleJSON, err := rdb.HGet(ctx, SCHEDULE_DEFS_KEY, scheduleID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule models.Schedule
+	if err := json.Unmarshal([]byte(scheduleJSON), &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListSchedules returns every schedule definition
+func ListSchedules() ([]models.Schedule, error) {
+	raws, err := rdb.HVals(ctx, SCHEDULE_DEFS_KEY).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make([]models.Schedule, 0, len(raws))
+	for _, raw := range raws {
+		var schedule models.Schedule
+		if err := json.Unmarshal([]byte(raw), &schedule); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// DeleteSchedule removes a schedule so it no longer fires.
+// It returns false if the schedule did not exist.
+func DeleteSchedule(scheduleID string) (bool, error) {
+	pipe := rdb.TxPipeline()
+	removed := pipe.HDel(ctx, SCHEDULE_DEFS_KEY, scheduleID)
+	pipe.ZRem(ctx, SCHEDULE_NEXT_KEY, scheduleID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
+
+// DueSchedules returns up to 'limit' schedules whose next tick is <= now
+func DueSchedules(limit int) ([]ScheduleTick, error) {
+	items, err := rdb.ZRangeByScoreWithScores(ctx, SCHEDULE_NEXT_KEY, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ticks := make([]ScheduleTick, 0, len(items))
+	for _, item := range items {
+		ticks = append(ticks, ScheduleTick{
+			ScheduleID: item.Member.(string),
+			At:         time.UnixMilli(int64(item.Score)),
+		})
+	}
+	return ticks, nil
+}
+
+// AdvanceSchedule stores the updated schedule and moves it from 'tick' to
+// schedule.NextRunAt. It returns false if another instance already advanced
+// it, or the schedule was deleted in the meantime.
+func AdvanceSchedule(schedule *models.Schedule, tick time.Time) (bool, error) {
+	scheduleJSON, err := json.Marshal(schedule)
+	if err != nil {
+		return false, err
+	}
+
+	keys := []string{SCHEDULE_DEFS_KEY, SCHEDULE_NEXT_KEY}
+	return advanceScheduleScript.Run(ctx, rdb, keys,
+		schedule.ID, tick.UnixMilli(), schedule.NextRunAt.UnixMilli(), scheduleJSON).Bool()
+}
@@ -0,0 +1,28 @@
+package redis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ============================================
+// Unique Tasks
+// ============================================
+//
+// A task submitted with unique_for takes a lock at UNIQUE_LOCK_PREFIX + its
+// unique key that expires after unique_for. While the lock is held, creating
+// another task with the same unique key returns the task holding the lock,
+// whatever its ID. The lock is taken in createTaskScript, in the same step
+// as the task is stored.
+
+const UNIQUE_LOCK_PREFIX = "task:unique:"
+
+// UniqueKey returns the key a task is deduplicated by: the client's
+// unique_key if given, otherwise a hash of the job type and payload
+func UniqueKey(jobType, payload, uniqueKey string) string {
+	if uniqueKey != "" {
+		return "key:" + uniqueKey
+	}
+	sum := sha256.Sum256([]byte(jobType + "\x00" + payload))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// ============================================
+// Waiting Index (oldest waiting task per job type)
+// ============================================
+//
+// WAITING_INDEX_PREFIX + jobType scores every task of that job type that is
+// sitting in a queue by the time it entered the queue (unix ms). Every script
+// that pushes a task into a queue or takes it out keeps the index in sync
+// through the Lua helpers below.
+//
+// Pushing a task also leaves a token in WAKEUP_PREFIX + the queue's key.
+// Idle workers block on those token lists rather than on the queues, so a
+// task is only ever taken by the atomic dequeue scripts (see inflight.go).
+
+const (
+	WAITING_INDEX_PREFIX = "task:waiting:"
+	WAKEUP_PREFIX        = "task:wakeup:"
+	// wakeupTokens caps the tokens kept per queue, which bounds the spurious
+	// wake-ups after a backlog was drained without blocking
+	wakeupTokens = 64
+)
+
+// luaWaitingIndex is prepended to scripts that move tasks in and out of queues.
+// mark_waiting(id, queue) and unmark_waiting(id) look up the job type from
+// the stored task, so callers only need the task ID and, when pushing it,
+// the key of the queue it went into.
+var luaWaitingIndex = `
+local function waiting_key(id)
+	local raw = redis.call('GET', '` + TASK_RESULT_PREFIX + `' .. id)
+	if not raw then
+		return nil
+	end
+	local job_type = cjson.decode(raw).job_type
+	if type(job_type) ~= 'string' then
+		return nil
+	end
+	return '` + WAITING_INDEX_PREFIX + `' .. job_type
+end
+local function mark_waiting(id, queue)
+	local key = waiting_key(id)
+	if key then
+		local now = redis.call('TIME')
+		redis.call('ZADD', key, 'NX', now[1] * 1000 + math.floor(now[2] / 1000), id)
+	end
+	local wakeup = '` + WAKEUP_PREFIX + `' .. queue
+	redis.call('LPUSH', wakeup, 1)
+	redis.call('LTRIM', wakeup, 0, ` + strconv.Itoa(wakeupTokens-1) + `)
+end
+local function unmark_waiting(id)
+	local key = waiting_key(id)
+	if key then
+		redis.call('ZREM', key, id)
+	end
+end
+`
+
+// withWaitingIndex builds a script that can call mark_waiting and unmark_waiting
+func withWaitingIndex(src string) *redis.Script {
+	return redis.NewScript(luaWaitingIndex + src)
+}
+
+// OldestWaitingByJobType returns the task that has been waiting longest in
+// any queue, per job type. Job types with nothing waiting are left out.
+func OldestWaitingByJobType() (map[string]models.WaitingTask, error) {
+	keys, err := rdb.Keys(ctx, WAITING_INDEX_PREFIX+"*").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	oldest := make(map[string]models.WaitingTask, len(keys))
+	for _, key := range keys {
+		items, err := rdb.ZRangeWithScores(ctx, key, 0, 0).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		since := time.UnixMilli(int64(items[0].Score))
+		oldest[strings.TrimPrefix(key, WAITING_INDEX_PREFIX)] = models.WaitingTask{
+			TaskID:       items[0].Member.(string),
+			WaitingSince: since,
+			WaitSeconds:  now.Sub(since).Seconds(),
+		}
+	}
+	return oldest, nil
+}
@@ -0,0 +1,106 @@
+package redis
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// ============================================
+// Worker Registry
+// ============================================
+//
+// Every worker stores a description of itself at WORKER_PREFIX + its ID and
+// rewrites it on every heartbeat. The entry expires unless it is refreshed,
+// so a worker that dies drops out of the registry on its own.
+// WORKER_REGISTRY_KEY remembers the IDs to list; IDs whose entry has expired
+// are pruned while listing. Entries live under their own prefix so that no
+// worker ID can collide with the registry key.
+
+const (
+	WORKER_PREFIX       = "worker:info:"
+	WORKER_REGISTRY_KEY = "worker:ids"
+)
+
+// RegisterWorker stores or refreshes a worker's entry, which expires after ttl
+func RegisterWorker(worker *models.WorkerInfo, ttl time.Duration) error {
+	workerJSON, err := json.Marshal(worker)
+	if err != nil {
+		return err
+	}
+
+	pipe := rdb.TxPipeline()
+	pipe.Set(ctx, WORKER_PREFIX+worker.ID, workerJSON, ttl)
+	pipe.SAdd(ctx, WORKER_REGISTRY_KEY, worker.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// DeregisterWorker removes a worker's entry once it has stopped
+func DeregisterWorker(workerID string) error {
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, WORKER_PREFIX+workerID)
+	pipe.SRem(ctx, WORKER_REGISTRY_KEY, workerID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetWorker returns a live worker by ID, or redis.Nil if there is none
+func GetWorker(workerID string) (*models.WorkerInfo, error) {
+	workerJSON, err := rdb.Get(ctx, WORKER_PREFIX+workerID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var worker models.WorkerInfo
+	if err := json.Unmarshal([]byte(workerJSON), &worker); err != nil {
+		return nil, err
+	}
+	return &worker, nil
+}
+
+// ListWorkers returns every live worker ordered by ID
+func ListWorkers() ([]*models.WorkerInfo, error) {
+	ids, err := rdb.SMembers(ctx, WORKER_REGISTRY_KEY).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []*models.WorkerInfo{}, nil
+	}
+	sort.Strings(ids)
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = WORKER_PREFIX + id
+	}
+	raws, err := rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make([]*models.WorkerInfo, 0, len(raws))
+	var expired []interface{}
+	for i, raw := range raws {
+		s, ok := raw.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		var worker models.WorkerInfo
+		if err := json.Unmarshal([]byte(s), &worker); err != nil {
+			return nil, err
+		}
+		workers = append(workers, &worker)
+	}
+
+	// Forget workers that stopped heartbeating
+	if len(expired) > 0 {
+		if err := rdb.SRem(ctx, WORKER_REGISTRY_KEY, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return workers, nil
+}
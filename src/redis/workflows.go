@@ -0,0 +1,303 @@
+package redis
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	models "github.com/yourusername/distributed-task-queue/src/api/models"
+)
+
+// ============================================
+// Workflows (task dependencies)
+// ============================================
+//
+// A workflow is a DAG of tasks. Tasks without parents are enqueued on
+// submission; the others are stored as "pending" with:
+//   - DEPENDENCIES_PREFIX + taskID: the parents that have not succeeded yet
+//   - DEPENDENTS_PREFIX + taskID: the children waiting on the task
+//   - PENDING_META_KEY: the queue each pending task is released into
+// When a task succeeds it is removed from its children's dependencies, and
+// children left without any are released into their queue. When a task fails
+// for good, everything downstream of it is skipped.
+
+const (
+	WORKFLOW_PREFIX     = "workflow:"
+	DEPENDENCIES_PREFIX = "task:deps:"
+	DEPENDENTS_PREFIX   = "task:dependents:"
+	PENDING_META_KEY    = "task:pending:meta"
+)
+
+// workflowEntry is how CreateWorkflow hands one task to createWorkflowScript
+type workflowEntry struct {
+	ID      string   `json:"id"`
+	Task    string   `json:"task"`
+	Parents []string `json:"parents"`
+	Meta    string   `json:"meta"`
+	TTL     int64    `json:"ttl"` // ms, 0 for none
+}
+
+// createWorkflowScript stores a workflow and all its tasks, records their
+// dependencies and enqueues the tasks without parents, all in one step.
+// Root tasks are only enqueued once every dependency is recorded, so a fast
+// root can't finish before its children know about it. Nothing is written if
+// the workflow exists (returns 0) or one of its task IDs is already taken by
+// another task (returns that ID); returns 1 once created.
+// KEYS: workflow key, pending meta
+// ARGV: workflow JSON, workflow TTL (ms), entries JSON, task key prefix,
+// dependencies prefix, dependents prefix
+var createWorkflowScript = withWaitingIndex(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local entries = cjson.decode(ARGV[3])
+for _, e in ipairs(entries) do
+	if redis.call('EXISTS', ARGV[4] .. e.id) == 1 then
+		return e.id
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+for _, e in ipairs(entries) do
+	if e.ttl > 0 then
+		redis.call('SET', ARGV[4] .. e.id, e.task, 'PX', e.ttl)
+	else
+		redis.call('SET', ARGV[4] .. e.id, e.task)
+	end
+	if #e.parents > 0 then
+		for _, parent in ipairs(e.parents) do
+			redis.call('SADD', ARGV[5] .. e.id, parent)
+			redis.call('SADD', ARGV[6] .. parent, e.id)
+		end
+		redis.call('HSET', KEYS[2], e.id, e.meta)
+	end
+end
+for _, e in ipairs(entries) do
+	if #e.parents == 0 then
+		local meta = cjson.decode(e.meta)
+		if meta.kind == 'zset' then
+			redis.call('ZADD', meta.queue, meta.score, e.id)
+		else
+			redis.call('LPUSH', meta.queue, e.id)
+		end
+		mark_waiting(e.id, meta.queue)
+	end
+end
+return 1
+`)
+
+// releaseDependentsScript removes a succeeded task from its children's
+// dependencies and enqueues the children that have no parents left,
+// switching them from "pending" to "queued". Running it twice is harmless.
+// KEYS: dependents of the task, pending meta
+// ARGV: task ID, task key prefix, dependencies prefix
+var releaseDependentsScript = withWaitingIndex(`
+local released = {}
+for _, id in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+	local deps = ARGV[3] .. id
+	redis.call('SREM', deps, ARGV[1])
+	if redis.call('SCARD', deps) == 0 then
+		local raw = redis.call('HGET', KEYS[2], id)
+		if raw then
+			redis.call('HDEL', KEYS[2], id)
+			local task = redis.call('GET', ARGV[2] .. id)
+			if task then
+				local updated = string.gsub(task, '"status":"pending"', '"status":"queued"', 1)
+				redis.call('SET', ARGV[2] .. id, updated, 'KEEPTTL')
+				local meta = cjson.decode(raw)
+				if meta.kind == 'zset' then
+					redis.call('ZADD', meta.queue, meta.score, id)
+				else
+					redis.call('LPUSH', meta.queue, id)
+				end
+				mark_waiting(id, meta.queue)
+				table.insert(released, id)
+			end
+		end
+	end
+end
+redis.call('DEL', KEYS[1])
+return released
+`)
+
+// CreateWorkflow stores a workflow and its tasks. Tasks must have their Queue
+// set and list their parents' full task IDs in DependsOn; the DAG is assumed
+// to be valid. It returns false if a workflow with the same ID already exists,
+// and false with the task's ID if a task with the ID of one of its tasks
+// already exists; nothing is stored in either case.
+func CreateWorkflow(workflow *models.Workflow, tasks []*models.Task) (bool, string, error) {
+	workflowJSON, err := json.Marshal(workflow)
+	if err != nil {
+		return false, "", err
+	}
+
+	entries := make([]workflowEntry, 0, len(tasks))
+	for _, task := range tasks {
+		if task.Queue != FIFO_QUEUE_NAME && task.Queue != PRIORITY_QUEUE_NAME {
+			if err := rdb.SAdd(ctx, QUEUE_REGISTRY_KEY, task.Queue).Err(); err != nil {
+				return false, "", err
+			}
+		}
+
+		meta := inFlightMeta{Queue: QueueKey(task.Queue), Kind: "list"}
+		if task.Queue == PRIORITY_QUEUE_NAME {
+			meta.Kind = "zset"
+			meta.Score = strconv.FormatFloat(taskScore(task), 'f', -1, 64)
+		}
+		metaJSON, err := json.Marshal(meta)
+		if err != nil {
+			return false, "", err
+		}
+		taskJSON, err := json.Marshal(task)
+		if err != nil {
+			return false, "", err
+		}
+
+		parents := task.DependsOn
+		if parents == nil {
+			// cjson decodes null as a userdata, not an empty table
+			parents = []string{}
+		}
+		entries = append(entries, workflowEntry{
+			ID:      task.ID,
+			Task:    string(taskJSON),
+			Parents: parents,
+			Meta:    string(metaJSON),
+			TTL:     taskTTL(task).Milliseconds(),
+		})
+	}
+	entriesJSON, err := json.Marshal(entries)
+	if err != nil {
+		return false, "", err
+	}
+
+	keys := []string{WORKFLOW_PREFIX + workflow.ID, PENDING_META_KEY}
+	result, err := createWorkflowScript.Run(ctx, rdb, keys,
+		workflowJSON, TASK_TTL.Milliseconds(), entriesJSON,
+		TASK_RESULT_PREFIX, DEPENDENCIES_PREFIX, DEPENDENTS_PREFIX).Result()
+	if err != nil {
+		return false, "", err
+	}
+	if takenID, ok := result.(string); ok {
+		return false, takenID, nil
+	}
+	return result == int64(1), "", nil
+}
+
+// GetWorkflow retrieves a workflow by ID
+func GetWorkflow(workflowID string) (*models.Workflow, error) {
+	raw, err := rdb.Get(ctx, WORKFLOW_PREFIX+workflowID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var workflow models.Workflow
+	if err := json.Unmarshal([]byte(raw), &workflow); err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// GetTasks retrieves several tasks at once. Tasks that no longer exist are
+// left out.
+func GetTasks(taskIDs []string) ([]*models.Task, error) {
+	if len(taskIDs) == 0 {
+		return []*models.Task{}, nil
+	}
+
+	keys := make([]string, len(taskIDs))
+	for i, id := range taskIDs {
+		keys[i] = TASK_RESULT_PREFIX + id
+	}
+	raws, err := rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]*models.Task, 0, len(raws))
+	for _, raw := range raws {
+		s, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		var task models.Task
+		if err := json.Unmarshal([]byte(s), &task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &task)
+	}
+	return tasks, nil
+}
+
+// ReleaseDependents is called once a task succeeded. It enqueues the tasks
+// that were only waiting on it and returns their IDs.
+func ReleaseDependents(taskID string) ([]string, error) {
+	keys := []string{DEPENDENTS_PREFIX + taskID, PENDING_META_KEY}
+	return releaseDependentsScript.Run(ctx, rdb, keys,
+		taskID, TASK_RESULT_PREFIX, DEPENDENCIES_PREFIX).StringSlice()
+}
+
+// SkipDependents is called once a task failed for good or was cancelled.
+// Every pending task downstream of it is marked "skipped" with the given
+// reason and will never run. It returns the IDs of the skipped tasks.
+func SkipDependents(taskID, reason string) ([]string, error) {
+	var skipped []string
+	queue := []string{taskID}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		children, err := rdb.SMembers(ctx, DEPENDENTS_PREFIX+parent).Result()
+		if err != nil {
+			return skipped, err
+		}
+		for _, id := range children {
+			task, err := GetTask(id)
+			if err == Nil {
+				continue
+			}
+			if err != nil {
+				return skipped, err
+			}
+			if task.Status != "pending" {
+				// Already skipped through another parent
+				continue
+			}
+
+			now := time.Now()
+			task.Status = "skipped"
+			task.CompletedAt = &now
+			task.Error = reason
+			if err := StoreTask(task); err != nil {
+				return skipped, err
+			}
+			// Only forget the task once it is stored as skipped, so a crash
+			// in between leaves it pending rather than lost
+			pipe := rdb.TxPipeline()
+			pipe.HDel(ctx, PENDING_META_KEY, id)
+			pipe.Del(ctx, DEPENDENCIES_PREFIX+id)
+			if _, err := pipe.Exec(ctx); err != nil {
+				return skipped, err
+			}
+			skipped = append(skipped, id)
+			queue = append(queue, id)
+		}
+		if err := rdb.Del(ctx, DEPENDENTS_PREFIX+parent).Err(); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
+
+// clearWorkflows removes every workflow and its dependency bookkeeping
+func clearWorkflows() error {
+	var keys []string
+	for _, pattern := range []string{WORKFLOW_PREFIX + "*", DEPENDENCIES_PREFIX + "*", DEPENDENTS_PREFIX + "*"} {
+		matched, err := rdb.Keys(ctx, pattern).Result()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, matched...)
+	}
+	keys = append(keys, PENDING_META_KEY)
+	return rdb.Del(ctx, keys...).Err()
+}
@@ -1,65 +1,489 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"math/rand"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	models "github.com/yourusername/distributed-task-queue/src/api/models"
+	"github.com/yourusername/distributed-task-queue/src/jobs"
 	r "github.com/yourusername/distributed-task-queue/src/redis"
 )
 
-// Global random number generator for simulating failures
-var rng = rand.New(rand.NewSource(time.Now().UnixNano()))
-
 const (
-	transientRate = 0.20 // 20% chance of transient (temporary) failure
-	permanentRate = 0.05 // 5% chance of permanent failure
+	transientRate = 0.20 // 20% chance of transient (temporary) failure of simulated jobs
+	permanentRate = 0.05 // 5% chance of permanent failure of simulated jobs
 	maxRetries    = 5
 	baseBackoff   = 200 * time.Millisecond
+	// defaultLease covers a task between dequeue and the first heartbeat,
+	// and job types without an entry in leaseByType
+	defaultLease = 30 * time.Second
+	// defaultTimeout applies to job types without an entry in timeoutByType
+	defaultTimeout = 30 * time.Second
+	// dequeueTimeout bounds how long a blocking dequeue waits on an empty queue
+	dequeueTimeout = 5 * time.Second
+	// reaperHold is how long the reaper owns an expired task while deciding its fate
+	reaperHold = 30 * time.Second
+	// slotReportInterval is how often a worker logs how many of its slots are busy
+	slotReportInterval = 30 * time.Second
+	// defaultGrace is how long a stopping worker lets running tasks finish
+	defaultGrace = 25 * time.Second
+	// registryInterval is how often a worker refreshes its registry entry,
+	// which expires after registryTTL without a refresh
+	registryInterval = 5 * time.Second
+	registryTTL      = 3 * registryInterval
+	// pausedPoll is how often a worker whose queues are all paused checks again
+	pausedPoll = 1 * time.Second
+	// shutdownHardStop is how long a stopping worker waits for handlers that
+	// ignore their cancelled context before handing their tasks back anyway
+	shutdownHardStop = 10 * time.Second
 )
 
+// running maps the IDs of tasks in progress to the cancel func of their context
+var running = struct {
+	sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+}{cancels: make(map[string]context.CancelCauseFunc)}
+
+// errShutdown is the cause of the cancellation of tasks still running when
+// the worker's grace period is over; they go back to their queue
+var errShutdown = errors.New("worker shutting down")
+
+// busySlots counts the slots of this process that are running a task
+var busySlots atomic.Int32
+
+// leaseByType is how long a worker may go without heartbeating before its
+// task is considered abandoned, per job type (overridable with -leases)
+var leaseByType = map[string]time.Duration{
+	"short": 30 * time.Second,
+	"long":  2 * time.Minute,
+}
+
+// timeoutByType is how long a task may run when it was submitted without a
+// timeout, per job type (overridable with -timeouts)
+var timeoutByType = map[string]time.Duration{
+	"short": 5 * time.Second,
+	"long":  30 * time.Second,
+}
+
 func main() {
-	// queueType := "fifo"
-	// queueType := "priority"
-	queueType := flag.String("queue", "fifo", "Queue type: fifo or priority")
+	// queues := "fifo"
+	// queues := "priority"
+	queues := flag.String("queues", "fifo", "Queues to consume with weights, e.g. critical=6,default=3,low=1 (fifo, priority or named queues)")
+	strict := flag.Bool("strict", false, "Consume queues in the listed order instead of by weight")
 	mode := flag.String("mode", "simple", "simple or retry")
+	workerID := flag.String("id", defaultWorkerID(), "Worker ID, keep it stable across restarts to recover in-flight tasks")
+	leases := flag.String("leases", "", "Lease per job type, e.g. short=30s,long=2m")
+	timeouts := flag.String("timeouts", "", "Default timeout per job type, e.g. short=5s,long=30s")
+	concurrency := flag.Int("concurrency", 1, "Number of tasks this process runs at once")
+	grace := flag.Duration("grace", defaultGrace, "How long running tasks may finish after SIGTERM or SIGINT before they are requeued")
 	flag.Parse()
 
+	if *concurrency < 1 {
+		log.Fatalf("Invalid -concurrency: must be at least 1")
+	}
+
+	if err := parseDurations(*leases, leaseByType); err != nil {
+		log.Fatalf("Invalid -leases: %v", err)
+	}
+	if err := parseDurations(*timeouts, timeoutByType); err != nil {
+		log.Fatalf("Invalid -timeouts: %v", err)
+	}
+	selector, err := parseQueues(*queues, *strict)
+	if err != nil {
+		log.Fatalf("Invalid -queues: %v", err)
+	}
+
+	// Every slot may hold a connection in a blocking dequeue, and needs
+	// another for heartbeats and stores while it runs a task
+	r.SetPoolSize(max(2*(*concurrency)+4, 10*runtime.GOMAXPROCS(0)))
 	r.InitRedis()
 	defer r.CloseRedis()
 
-	StartWorkerWithQueue(*queueType, *mode)
+	// Stop dequeuing on SIGTERM (docker stop, ECS) or SIGINT (Ctrl-C)
+	stop, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stopSignals()
+	go func() {
+		// A second signal kills the worker right away
+		<-stop.Done()
+		stopSignals()
+	}()
+
+	StartWorkerWithQueues(stop, selector, *mode, *workerID, *concurrency, *grace)
+}
+
+// defaultWorkerID combines hostname and pid so workers sharing a host don't collide
+func defaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// parseDurations overrides durations per job type from a "type=duration,..." list
+func parseDurations(spec string, byType map[string]time.Duration) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		jobType, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return fmt.Errorf("expected type=duration, got %q", entry)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("duration for %q must be positive", jobType)
+		}
+		byType[jobType] = d
+	}
+	return nil
+}
+
+// weightedQueue is one queue a worker consumes and its share of dequeues
+type weightedQueue struct {
+	name    string
+	key     string
+	weight  int
+	current int // smooth weighted round-robin state
+}
+
+// queueSelector picks the order in which a worker tries its queues on every
+// dequeue. In weighted mode the first queue follows smooth weighted
+// round-robin, so under load each queue gets its weight's share of dequeues;
+// the other queues follow so an empty queue never leaves the worker idle.
+// In strict mode queues are always tried in the listed order.
+type queueSelector struct {
+	queues   []*weightedQueue
+	strict   bool
+	priority bool // consuming the built-in priority queue (a ZSET)
+}
+
+// parseQueues builds a queueSelector from a "name=weight,..." list.
+// Weights default to 1. The built-in priority queue can only be consumed on its own.
+func parseQueues(spec string, strict bool) (*queueSelector, error) {
+	selector := &queueSelector{strict: strict}
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, hasWeight := strings.Cut(strings.TrimSpace(entry), "=")
+		if !r.ValidQueueName(name) {
+			return nil, fmt.Errorf("invalid queue name %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("queue %q listed twice", name)
+		}
+		seen[name] = true
+
+		weight := 1
+		if hasWeight {
+			w, err := strconv.Atoi(value)
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("weight of %q must be a positive integer", name)
+			}
+			weight = w
+		}
+		if name == r.PRIORITY_QUEUE_NAME {
+			selector.priority = true
+		}
+		selector.queues = append(selector.queues, &weightedQueue{name: name, key: r.QueueKey(name), weight: weight})
+	}
+	if selector.priority && len(selector.queues) > 1 {
+		return nil, fmt.Errorf("%q can't be combined with other queues", r.PRIORITY_QUEUE_NAME)
+	}
+	return selector, nil
+}
+
+// order returns the keys of the queues to try for the next dequeue, leaving
+// out paused queues
+func (s *queueSelector) order(paused map[string]bool) []string {
+	active := make([]*weightedQueue, 0, len(s.queues))
+	for _, q := range s.queues {
+		if !paused[q.name] {
+			active = append(active, q)
+		}
+	}
+
+	keys := make([]string, 0, len(active))
+	if s.strict || len(active) <= 1 {
+		for _, q := range active {
+			keys = append(keys, q.key)
+		}
+		return keys
+	}
+
+	total := 0
+	var first *weightedQueue
+	for _, q := range active {
+		q.current += q.weight
+		total += q.weight
+		if first == nil || q.current > first.current {
+			first = q
+		}
+	}
+	first.current -= total
+
+	keys = append(keys, first.key)
+	for _, q := range active {
+		if q != first {
+			keys = append(keys, q.key)
+		}
+	}
+	return keys
+}
+
+// clone returns a selector over the same queues with its own round-robin state
+func (s *queueSelector) clone() *queueSelector {
+	c := &queueSelector{strict: s.strict, priority: s.priority}
+	for _, q := range s.queues {
+		copied := *q
+		copied.current = 0
+		c.queues = append(c.queues, &copied)
+	}
+	return c
 }
 
-// StartWorkerWithQueue starts a worker with specified queue type
-// queueType can be "fifo" or "priority"
-func StartWorkerWithQueue(queueType, mode string) {
-	log.Printf("Worker started (queue: %s), polling for tasks...", queueType)
+// weights lists the queues with their weights, e.g. ["critical=6", "low=1"]
+func (s *queueSelector) weights() []string {
+	parts := make([]string, 0, len(s.queues))
+	for _, q := range s.queues {
+		parts = append(parts, fmt.Sprintf("%s=%d", q.name, q.weight))
+	}
+	return parts
+}
+
+// String describes the queues for logging, e.g. "critical=6,default=3,low=1"
+func (s *queueSelector) String() string {
+	if s.strict {
+		return strings.Join(s.weights(), ",") + " (strict)"
+	}
+	return strings.Join(s.weights(), ",")
+}
+
+// leaseFor returns the lease length for a job type
+func leaseFor(jobType string) time.Duration {
+	if d, ok := leaseByType[jobType]; ok {
+		return d
+	}
+	return defaultLease
+}
+
+// timeoutFor returns how long a task may run: its own timeout, or the
+// default of its job type
+func timeoutFor(task *models.Task) time.Duration {
+	if task.Timeout > 0 {
+		return time.Duration(task.Timeout) * time.Second
+	}
+	if d, ok := timeoutByType[task.JobType]; ok {
+		return d
+	}
+	return defaultTimeout
+}
+
+// StartWorkerWithQueues runs a worker consuming the queues of the selector
+// with concurrency slots, each running one task at a time, until stop is done.
+// Running tasks then get the grace period to finish before they are
+// requeued, and StartWorkerWithQueues returns once the worker has stopped.
+func StartWorkerWithQueues(stop context.Context, queues *queueSelector, mode, workerID string, concurrency int, grace time.Duration) {
+	log.Printf("Worker %s started (queues: %s, concurrency: %d), waiting for tasks...", workerID, queues, concurrency)
+
+	// Hand back anything this worker was running before it restarted
+	if ids, err := r.RequeueWorkerInFlight(workerID); err != nil {
+		log.Printf("Failed to recover in-flight tasks: %v", err)
+	} else if len(ids) > 0 {
+		for _, id := range ids {
+			markQueued(id)
+		}
+		log.Printf("→ Recovered %d in-flight tasks from previous run", len(ids))
+	}
+
+	// Announce the worker in the registry until it has stopped
+	hostname, _ := os.Hostname()
+	deregister := startRegistration(stop, &models.WorkerInfo{
+		ID:          workerID,
+		Hostname:    hostname,
+		Queues:      queues.weights(),
+		Strict:      queues.strict,
+		Mode:        mode,
+		Concurrency: concurrency,
+		StartedAt:   time.Now(),
+	})
+
+	// Background loops stop with the worker
+	var background sync.WaitGroup
+
+	// Requeue or fail tasks whose worker stopped heartbeating
+	startLeaseReaper(stop, &background, workerID)
+
+	// Stop running tasks when the API cancels them
+	startCancelListener()
+
+	// Move delayed tasks into their queue once they are due
+	startDelayedScheduler(stop, &background)
 
 	//Start a background goroutine to handle retry scheduling
 	if mode == "retry" {
-		startRetryScheduler()
+		startRetryScheduler(stop, &background)
+	}
+
+	// Log how busy the slots are
+	startSlotReporter(stop, &background, workerID, concurrency)
+
+	// Slots share the worker ID and the Redis client; each keeps its own
+	// queue rotation and random source
+	var slots sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		slots.Add(1)
+		go func(queues *queueSelector, rng *rand.Rand) {
+			defer slots.Done()
+			runSlot(stop, queues, rng, mode, workerID)
+		}(queues.clone(), rand.New(rand.NewSource(time.Now().UnixNano()+int64(i))))
+	}
+	slotsDone := make(chan struct{})
+	go func() {
+		slots.Wait()
+		close(slotsDone)
+	}()
+
+	<-stop.Done()
+	log.Printf("Worker %s stopping, giving %d running tasks up to %v to finish...", workerID, busySlots.Load(), grace)
+	shutdown(workerID, grace, slotsDone)
+	background.Wait()
+	deregister()
+	log.Printf("Worker %s stopped", workerID)
+}
+
+// startRegistration registers the worker and refreshes its entry with the
+// tasks it is running every registryInterval, and once more when it starts
+// stopping. The returned func stops the refreshes and removes the entry.
+func startRegistration(stop context.Context, info *models.WorkerInfo) func() {
+	refresh := func() {
+		info.Status = "running"
+		if stop.Err() != nil {
+			info.Status = "stopping"
+		}
+		info.LastHeartbeat = time.Now()
+		info.CurrentTasks = runningTaskIDs()
+		if err := r.RegisterWorker(info, registryTTL); err != nil {
+			log.Printf("Failed to refresh worker registration: %v", err)
+		}
+	}
+	refresh()
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(registryInterval)
+		defer ticker.Stop()
+
+		stopping := stop.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-stopping:
+				stopping = nil
+				refresh()
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+		if err := r.DeregisterWorker(info.ID); err != nil {
+			log.Printf("Failed to deregister worker: %v", err)
+		}
+	}
+}
+
+// shutdown waits for the slots to finish their tasks. Once the grace period
+// is over the tasks still running are cancelled with errShutdown, which puts
+// them back in their queue; if their handlers don't return either, whatever
+// is left in this worker's processing list is requeued.
+func shutdown(workerID string, grace time.Duration, slotsDone <-chan struct{}) {
+	select {
+	case <-slotsDone:
+		return
+	case <-time.After(grace):
+	}
+
+	log.Printf("Grace period over, requeueing %d running tasks", busySlots.Load())
+	running.Lock()
+	for _, cancel := range running.cancels {
+		cancel(errShutdown)
 	}
+	running.Unlock()
 
-	// Infinite loop: continuously poll for tasks
-	for {
+	select {
+	case <-slotsDone:
+		return
+	case <-time.After(shutdownHardStop):
+	}
+
+	ids, err := r.RequeueWorkerInFlight(workerID)
+	if err != nil {
+		log.Printf("Failed to requeue in-flight tasks: %v", err)
+		return
+	}
+	for _, id := range ids {
+		markQueued(id)
+	}
+	log.Printf("→ Requeued %d tasks whose handlers did not stop", len(ids))
+}
+
+// runSlot dequeues and runs tasks one at a time until stop is done
+func runSlot(stop context.Context, queues *queueSelector, rng *rand.Rand, mode, workerID string) {
+	// Loop until the worker stops: block until a task arrives
+	for stop.Err() == nil {
 		var taskID string
 		var err error
 
-		// Dequeue from specified queue type
-		if queueType == "priority" {
-			taskID, err = r.DequeuePriority()
+		// Skip paused queues, and wait while all of them are paused
+		paused, err := r.PausedQueues()
+		if err != nil {
+			log.Printf("Error checking paused queues: %v", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		keys := queues.order(paused)
+		if len(keys) == 0 {
+			select {
+			case <-stop.Done():
+			case <-time.After(pausedPoll):
+			}
+			continue
+		}
+
+		// Dequeue from the selected queues into this worker's processing list
+		if queues.priority {
+			taskID, err = r.BlockingDequeuePriority(workerID, defaultLease, dequeueTimeout, keys...)
 		} else {
-			taskID, err = r.DequeueFIFO()
+			taskID, err = r.BlockingDequeueFIFO(workerID, defaultLease, dequeueTimeout, keys...)
 		}
 
 		// Handle empty queue
 		if err == redis.Nil {
-			// Queue is empty, wait before retrying
-			time.Sleep(100 * time.Millisecond)
+			// Nothing arrived within dequeueTimeout, block again
 			continue
 		}
 
@@ -70,55 +494,322 @@ func StartWorkerWithQueue(queueType, mode string) {
 			continue
 		}
 
+		// Arrived while the worker was stopping, hand it back untouched
+		if stop.Err() != nil {
+			if err := r.RequeueInFlight(workerID, taskID); err != nil {
+				log.Printf("Failed to requeue task %s: %v", taskID, err)
+			}
+			return
+		}
+
 		// Get task details from Redis
 		task, err := r.GetTask(taskID)
+		if err == redis.Nil {
+			// The task record is gone, nothing left to run
+			log.Printf("Task %s no longer exists, dropping it", taskID)
+			ack(workerID, taskID)
+			continue
+		}
 		if err != nil {
+			// Leave it in-flight so the reaper picks it up once the lease expires
 			log.Printf("Failed to get task %s: %v", taskID, err)
 			continue
 		}
 
+		// Already finished: cancelled on its way to this worker, or recovered
+		// from a previous run that stored the outcome but died before the ack
+		if settleFinished(workerID, task) {
+			continue
+		}
+
+		// Its queue was paused while this worker was blocked on it
+		if queuePaused(queues, task) {
+			if err := r.RequeueInFlight(workerID, task.ID); err != nil {
+				log.Printf("Failed to requeue task %s of paused queue: %v", task.ID, err)
+			}
+			continue
+		}
+
+		// Hand the results of the task's parents to it (chains and chords)
+		if err := collectInputs(task); err != nil {
+			// Leave it in-flight so the reaper picks it up once the lease expires
+			log.Printf("Failed to collect inputs of task %s: %v", task.ID, err)
+			continue
+		}
+
+		// Keep the lease alive while the task runs
+		busySlots.Add(1)
+		stopHeartbeat := startHeartbeat(workerID, task, time.Now().Add(timeoutFor(task)))
+		taskCtx, cancel := trackRunning(task.ID)
+
+		// Tasks are only acked once their outcome is safely stored in Redis
 		if mode == "retry" {
-			processTaskWithFailureAndRetry(task)
+			processTaskWithFailureAndRetry(taskCtx, workerID, task, rng)
 		} else {
-			processTaskSimple(task)
+			processTaskSimple(taskCtx, workerID, task)
 		}
+		untrackRunning(task.ID)
+		cancel(nil)
+		stopHeartbeat()
+		busySlots.Add(-1)
 	}
 }
 
+// startSlotReporter periodically logs how many slots are running a task
+func startSlotReporter(stop context.Context, wg *sync.WaitGroup, workerID string, concurrency int) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(slotReportInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop.Done():
+				return
+			case <-ticker.C:
+			}
+
+			log.Printf("Worker %s: %d/%d slots busy", workerID, busySlots.Load(), concurrency)
+		}
+	}()
+}
+
+// trackRunning creates the context a task runs under and registers it so the
+// task can be cancelled. A cancellation requested before the task got here
+// cancels the context right away.
+func trackRunning(taskID string) (context.Context, context.CancelCauseFunc) {
+	taskCtx, cancel := context.WithCancelCause(context.Background())
+
+	running.Lock()
+	running.cancels[taskID] = cancel
+	running.Unlock()
+
+	if requested, err := r.IsCancelRequested(taskID); err != nil {
+		log.Printf("Failed to check cancellation of task %s: %v", taskID, err)
+	} else if requested {
+		cancel(nil)
+	}
+	return taskCtx, cancel
+}
+
+// queuePaused reports whether the queue a task was dequeued from is paused
+func queuePaused(queues *queueSelector, task *models.Task) bool {
+	name := task.Queue
+	if queues.priority {
+		name = r.PRIORITY_QUEUE_NAME
+	} else if name == "" || name == r.PRIORITY_QUEUE_NAME {
+		// The list a task waits in, see listQueueKey in the redis package
+		name = r.FIFO_QUEUE_NAME
+	}
+	paused, err := r.IsQueuePaused(name)
+	if err != nil {
+		// Run it rather than leave it waiting on a guess
+		log.Printf("Failed to check whether queue %s is paused: %v", name, err)
+		return false
+	}
+	return paused
+}
+
+// runningTaskIDs returns the IDs of the tasks in progress in ID order
+func runningTaskIDs() []string {
+	running.Lock()
+	ids := make([]string, 0, len(running.cancels))
+	for id := range running.cancels {
+		ids = append(ids, id)
+	}
+	running.Unlock()
+
+	sort.Strings(ids)
+	return ids
+}
+
+// untrackRunning forgets a task once it has stopped running
+func untrackRunning(taskID string) {
+	running.Lock()
+	delete(running.cancels, taskID)
+	running.Unlock()
+}
+
+// startCancelListener cancels the context of running tasks whose
+// cancellation is published by the API
+func startCancelListener() {
+	ids := r.SubscribeCancellations()
+	go func() {
+		for id := range ids {
+			running.Lock()
+			cancel, ok := running.cancels[id]
+			running.Unlock()
+			if ok {
+				log.Printf("→ Cancelling running task %s", id)
+				cancel(nil)
+			}
+		}
+	}()
+}
+
+// runHandler runs the task with the handler of its job type, bounded by the
+// task's timeout. Whatever the handler returned, it returns the cause of the
+// cancellation if ctx was cancelled (context.Canceled, or errShutdown) and
+// context.DeadlineExceeded if the task ran past its timeout.
+// The handler runs on its own goroutine, so one that ignores its context
+// can't hold the slot past the timeout; it is left to finish in the
+// background on a copy of the task and whatever it returns then is dropped.
+// The latest progress the handler reported is set on task.
+func runHandler(ctx context.Context, workerID string, task *models.Task) (string, error) {
+	handler, ok := jobs.Lookup(task.JobType)
+	if !ok {
+		return "", jobs.Permanent(fmt.Errorf("unknown job type %q", task.JobType))
+	}
+
+	runCtx, cancel := context.WithTimeout(jobs.WithProgress(ctx, workerID, task.ID), timeoutFor(task))
+	defer cancel()
+	snapshot := *task
+
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			// A panicking handler fails its task instead of the worker
+			if p := recover(); p != nil {
+				done <- outcome{err: fmt.Errorf("handler panicked: %v", p)}
+			}
+		}()
+		result, err := handler(runCtx, &snapshot)
+		done <- outcome{result, err}
+	}()
+
+	var o outcome
+	select {
+	case o = <-done:
+	case <-runCtx.Done():
+		// A handler that finished at the same moment still counts
+		select {
+		case o = <-done:
+		default:
+			o.err = runCtx.Err()
+		}
+	}
+	task.Progress = jobs.LastProgress(runCtx)
+
+	if o.err == nil {
+		return o.result, nil
+	}
+	if ctx.Err() != nil {
+		return "", context.Cause(ctx)
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		return "", context.DeadlineExceeded
+	}
+	return o.result, o.err
+}
+
+// ack removes a task from this worker's processing list
+func ack(workerID, taskID string) {
+	owned, err := r.AckTask(workerID, taskID)
+	if err != nil {
+		log.Printf("Failed to ack task %s: %v", taskID, err)
+	} else if !owned {
+		log.Printf("Lease on task %s was lost before ack, the reaper has taken it over", taskID)
+	}
+}
+
+// startHeartbeat switches the task to the lease of its job type and keeps
+// extending it every third of the lease until the returned stop func is
+// called or deadline (the end of the task's timeout) passes. The last
+// extension leaves the worker a lease to store the outcome in; a worker stuck
+// beyond that loses the task to the reaper.
+func startHeartbeat(workerID string, task *models.Task, deadline time.Time) func() {
+	lease := leaseFor(task.JobType)
+	extend := func() bool {
+		owned, err := r.ExtendLease(workerID, task.ID, lease)
+		if err != nil {
+			log.Printf("Failed to extend lease on task %s: %v", task.ID, err)
+			return true
+		}
+		if !owned {
+			log.Printf("Lease on task %s was lost, stopping heartbeat", task.ID)
+		}
+		return owned
+	}
+
+	done := make(chan struct{})
+	if !extend() {
+		return func() {}
+	}
+
+	go func() {
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if time.Now().After(deadline) {
+					log.Printf("Task %s ran past its timeout, stopping heartbeat", task.ID)
+					return
+				}
+				if !extend() {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
 // processTask without retry
-func processTaskSimple(task *models.Task) {
+func processTaskSimple(ctx context.Context, workerID string, task *models.Task) {
 	log.Printf("Processing task: %s (type: %s)", task.ID, task.JobType)
 
 	// Update status to running
 	now := time.Now()
 	task.Status = "running"
 	task.StartedAt = &now
+	task.Progress = nil
 	err := r.StoreTask(task)
 	if err != nil {
 		log.Printf("Failed to update task status to running: %v", err)
 		return
 	}
 
-	// Simulate work based on job type
-	if task.JobType == "short" {
-		time.Sleep(500 * time.Millisecond) // Short job: 500ms
-	} else if task.JobType == "long" {
-		time.Sleep(3 * time.Second) // Long job: 3 seconds
-	} else {
-		// Unknown job type, default to short
-		time.Sleep(500 * time.Millisecond)
+	// Run the handler of the job type; no retries without the retry scheduler
+	result, err := runHandler(ctx, workerID, task)
+	if errors.Is(err, errShutdown) {
+		requeueOnShutdown(workerID, task)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		finalizeCancelled(workerID, task)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		finalizeFailed(workerID, task, "timeout")
+		return
+	}
+	if err != nil {
+		finalizeFailed(workerID, task, err.Error())
+		return
 	}
 
 	// Update status to success
 	completed := time.Now()
 	task.Status = "success"
 	task.CompletedAt = &completed
-	task.Result = "Task completed successfully"
+	task.Result = result
 	err = r.StoreTask(task)
 	if err != nil {
 		log.Printf("Failed to update task status to success: %v", err)
 		return
 	}
+	releaseDependents(task)
+	ack(workerID, task.ID)
 
 	// Calculate and log latency
 	latency := completed.Sub(task.SubmittedAt)
@@ -126,48 +817,67 @@ func processTaskSimple(task *models.Task) {
 }
 
 // processTask with retry
-func processTaskWithFailureAndRetry(task *models.Task) {
+func processTaskWithFailureAndRetry(ctx context.Context, workerID string, task *models.Task, rng *rand.Rand) {
 	log.Printf("Processing task: %s (type: %s)", task.ID, task.JobType)
 
 	// Update status to running
 	now := time.Now()
 	task.Status = "running"
 	task.StartedAt = &now
+	task.Progress = nil
 	err := r.StoreTask(task)
 	if err != nil {
 		log.Printf("Failed to update task status to running: %v", err)
 		return
 	}
 
-	// Simulate work based on job type
-	if task.JobType == "short" {
-		time.Sleep(500 * time.Millisecond) // Short job: 500ms
-	} else if task.JobType == "long" {
-		time.Sleep(3 * time.Second) // Long job: 3 seconds
-	} else {
-		// Unknown job type, default to short
-		time.Sleep(500 * time.Millisecond)
+	// Run the handler of the job type
+	result, err := runHandler(ctx, workerID, task)
+	if errors.Is(err, errShutdown) {
+		requeueOnShutdown(workerID, task)
+		return
 	}
-	// 20% chance of transient failure (can be retried)
-	u := rng.Float64() // random float number
-	if u < permanentRate {
-		finalizeFailed(task, "permanent error")
+	if errors.Is(err, context.Canceled) {
+		finalizeCancelled(workerID, task)
 		return
-	} else if u < permanentRate+transientRate { //  0.05 ≤ u < 0.25
-		handleTransient(task)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		handleTransient(workerID, task, "timeout")
+		return
+	}
+	if jobs.IsPermanent(err) {
+		finalizeFailed(workerID, task, err.Error())
+		return
+	}
+	if err != nil {
+		handleTransient(workerID, task, err.Error())
 		return
 	}
+	// Simulated jobs fail at random for experiment 3: 5% permanently, 20%
+	// with a transient failure (can be retried)
+	if jobs.Simulated(task.JobType) {
+		u := rng.Float64() // random float number
+		if u < permanentRate {
+			finalizeFailed(workerID, task, "permanent error")
+			return
+		} else if u < permanentRate+transientRate { //  0.05 ≤ u < 0.25
+			handleTransient(workerID, task, "transient error")
+			return
+		}
+	}
 
 	// Update status to success
 	completed := time.Now()
 	task.Status = "success"
 	task.CompletedAt = &completed
-	task.Result = "Task completed successfully"
+	task.Result = result
 	err = r.StoreTask(task)
 	if err != nil {
 		log.Printf("Failed to update task status to success: %v", err)
 		return
 	}
+	releaseDependents(task)
+	ack(workerID, task.ID)
 
 	// Calculate and log latency
 	latency := completed.Sub(task.SubmittedAt)
@@ -176,51 +886,311 @@ func processTaskWithFailureAndRetry(task *models.Task) {
 
 // For tasks that are ready to be retried.
 // Tasks whose retry time has arrived, and re-enqueues them back into the appropriate queue.
-func startRetryScheduler() {
+func startRetryScheduler(stop context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		// Create a ticker that fires every 200ms
 		ticker := time.NewTicker(200 * time.Millisecond)
 		defer ticker.Stop()
 
-		// Infinite loop: check for retries every 200ms
-		for range ticker.C {
-			// Query Redis for up to 128 tasks whose retry time has arrived
-			ids, err := r.PopDueRetries(128)
+		// Check for retries every 200ms until the worker stops
+		for {
+			select {
+			case <-stop.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// Move up to 128 tasks whose retry time has arrived back into
+			// their queue in one atomic step, so schedulers never race
+			ids, err := r.PromoteDueRetries(128)
 			if err != nil {
 				log.Printf("retry scan error: %v", err)
 				continue
 			}
-			// Re-enqueue each task back into the appropriate queue
 			for _, id := range ids {
-				if err := r.ReenqueueByType(id); err != nil {
-					log.Printf("reenqueue retry %s error: %v", id, err)
-				} else {
-					log.Printf("→ Re-enqueued retry task %s", id)
-				}
+				log.Printf("→ Re-enqueued retry task %s", id)
+			}
+		}
+	}()
+}
+
+// For tasks submitted with run_at or delay_seconds.
+// Moves tasks whose run time has arrived into their queue. Promotion is
+// atomic, so every worker can run this safely.
+func startDelayedScheduler(stop context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop.Done():
+				return
+			case <-ticker.C:
+			}
+
+			ids, err := r.PromoteDueScheduled(128)
+			if err != nil {
+				log.Printf("scheduled scan error: %v", err)
+				continue
+			}
+			for _, id := range ids {
+				log.Printf("→ Enqueued scheduled task %s", id)
+			}
+		}
+	}()
+}
+
+// Periodically claims tasks whose lease has expired because their worker
+// died or hung. Claiming is atomic, so every worker can run the reaper safely.
+func startLeaseReaper(stop context.Context, wg *sync.WaitGroup, workerID string) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop.Done():
+				return
+			case <-ticker.C:
+			}
+
+			leases, err := r.ClaimExpiredLeases(workerID, 128, reaperHold)
+			if err != nil {
+				log.Printf("lease reaper error: %v", err)
+				continue
+			}
+			for _, lease := range leases {
+				reapExpiredLease(workerID, lease)
 			}
 		}
 	}()
 }
 
-// Mark a task as permanently failed (no more retries)
-func finalizeFailed(task *models.Task, reason string) {
+// reapExpiredLease counts an expired lease as a failed attempt: the task goes
+// back to its queue, or is failed once it has used up its retries.
+// On errors the task stays claimed and is reaped again after reaperHold.
+func reapExpiredLease(workerID string, lease r.ExpiredLease) {
+	task, err := r.GetTask(lease.TaskID)
+	if err == redis.Nil {
+		ack(workerID, lease.TaskID)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get expired task %s: %v", lease.TaskID, err)
+		return
+	}
+
+	// The worker stored the outcome but died before handing the task off
+	if settleFinished(workerID, task) {
+		return
+	}
+
+	reason := fmt.Sprintf("lease expired on worker %s", lease.WorkerID)
+	task.RetryCount++
+	if task.RetryCount > maxRetries {
+		finalizeFailed(workerID, task, reason)
+		return
+	}
+
+	task.Status = "queued"
+	task.StartedAt = nil
+	task.Error = reason
+	if err := r.StoreTask(task); err != nil {
+		log.Printf("Failed to store expired task %s: %v", task.ID, err)
+		return
+	}
+	if err := r.RequeueInFlight(workerID, task.ID); err != nil {
+		log.Printf("Failed to requeue expired task %s: %v", task.ID, err)
+		return
+	}
+	log.Printf("→ Requeued task %s after %s (retry=%d)", task.ID, reason, task.RetryCount)
+}
+
+// settleFinished hands off a task whose outcome is already stored instead of
+// running it again: dependents are released or skipped, failed tasks are
+// dead-lettered, and the task is acked. It returns false if the task still
+// has to run.
+func settleFinished(workerID string, task *models.Task) bool {
+	switch task.Status {
+	case "success":
+		releaseDependents(task)
+		ack(workerID, task.ID)
+	case "cancelled":
+		skipDependents(task)
+		ack(workerID, task.ID)
+	case "failed":
+		finalizeFailed(workerID, task, task.Error)
+	case "skipped":
+		ack(workerID, task.ID)
+	default:
+		return false
+	}
+	return true
+}
+
+// collectInputs fills task.Inputs with the results of the tasks it depends
+// on, in depends_on order. They are stored with the task once it is running.
+func collectInputs(task *models.Task) error {
+	if len(task.DependsOn) == 0 || task.Inputs != nil {
+		return nil
+	}
+	parents, err := r.GetTasks(task.DependsOn)
+	if err != nil {
+		return err
+	}
+
+	results := make(map[string]string, len(parents))
+	for _, parent := range parents {
+		results[parent.ID] = parent.Result
+	}
+	task.Inputs = make([]string, len(task.DependsOn))
+	for i, id := range task.DependsOn {
+		// A parent whose record expired contributes an empty result
+		task.Inputs[i] = results[id]
+	}
+	return nil
+}
+
+// releaseDependents enqueues the workflow tasks that were only waiting on
+// this task. It runs before the ack, so a crash in between is retried by the reaper.
+func releaseDependents(task *models.Task) {
+	if task.WorkflowID == "" {
+		return
+	}
+	ids, err := r.ReleaseDependents(task.ID)
+	if err != nil {
+		log.Printf("Failed to release dependents of task %s: %v", task.ID, err)
+		return
+	}
+	for _, id := range ids {
+		log.Printf("→ Released task %s (dependency %s succeeded)", id, task.ID)
+	}
+}
+
+// skipDependents marks every workflow task downstream of a task that failed
+// or was cancelled as skipped
+func skipDependents(task *models.Task) {
+	if task.WorkflowID == "" {
+		return
+	}
+	reason := fmt.Sprintf("dependency %s %s", task.ID, task.Status)
+	ids, err := r.SkipDependents(task.ID, reason)
+	if err != nil {
+		log.Printf("Failed to skip dependents of task %s: %v", task.ID, err)
+		return
+	}
+	if len(ids) > 0 {
+		log.Printf("→ Skipped %d tasks depending on %s", len(ids), task.ID)
+	}
+}
+
+// requeueOnShutdown puts a task the worker stopped before it finished back
+// at the head of its queue. It does not count as a retry.
+func requeueOnShutdown(workerID string, task *models.Task) {
+	task.Status = "queued"
+	task.StartedAt = nil
+	task.Progress = nil
+	if err := r.StoreTask(task); err != nil {
+		log.Printf("Failed to store stopped task %s: %v", task.ID, err)
+		return
+	}
+	if err := r.RequeueInFlight(workerID, task.ID); err != nil {
+		log.Printf("Failed to requeue stopped task %s: %v", task.ID, err)
+		return
+	}
+	log.Printf("→ Requeued task %s on shutdown", task.ID)
+}
+
+// markQueued resets the status of a task that was handed back to its queue
+// while its record still says it is running
+func markQueued(taskID string) {
+	task, err := r.GetTask(taskID)
+	if err != nil {
+		log.Printf("Failed to get requeued task %s: %v", taskID, err)
+		return
+	}
+	if task.Status != "running" {
+		return
+	}
+	task.Status = "queued"
+	task.StartedAt = nil
+	task.Progress = nil
+	if err := r.StoreTask(task); err != nil {
+		log.Printf("Failed to store requeued task %s: %v", taskID, err)
+	}
+}
+
+// Mark a task as cancelled after its context was cancelled and ack it
+func finalizeCancelled(workerID string, task *models.Task) {
 	t := time.Now()
+	task.Status = "cancelled"
+	task.CompletedAt = &t
+	task.Error = "cancelled by user"
+	if err := r.StoreTask(task); err != nil {
+		log.Printf("Failed to store cancelled task: %v", err)
+		return
+	}
+	if err := r.ClearCancelRequest(task.ID); err != nil {
+		log.Printf("Failed to clear cancel request for task %s: %v", task.ID, err)
+	}
+	skipDependents(task)
+	ack(workerID, task.ID)
+	log.Printf("Cancelled task %s (type=%s)", task.ID, task.JobType)
+}
+
+// Mark a task as permanently failed (no more retries) and move it from this
+// worker to the dead-letter queue
+func finalizeFailed(workerID string, task *models.Task, reason string) {
+	t := time.Now()
+	if task.CompletedAt != nil {
+		t = *task.CompletedAt
+	}
+	lastError := task.Error
 	task.Status = "failed"
 	task.CompletedAt = &t
 	task.Error = reason
 	// Save final state to Redis
 	if err := r.StoreTask(task); err != nil {
 		log.Printf("Failed to store failed task: %v", err)
+		return
+	}
+	skipDependents(task)
+
+	owned, err := r.DeadLetterInFlight(workerID, models.DeadLetterEntry{
+		TaskID:     task.ID,
+		JobType:    task.JobType,
+		Reason:     reason,
+		LastError:  lastError,
+		RetryCount: task.RetryCount,
+		FailedAt:   t,
+	})
+	if err != nil {
+		log.Printf("Failed to dead-letter task %s: %v", task.ID, err)
+		return
+	}
+	if !owned {
+		log.Printf("Lease on task %s was lost before dead-lettering it", task.ID)
 	}
 	log.Printf("Failed task %s (type=%s, reason=%s, retry_count=%d)",
 		task.ID, task.JobType, reason, task.RetryCount)
 }
 
-func handleTransient(task *models.Task) {
+// handleTransient records a retryable error and schedules a retry with
+// exponential backoff, handing the task from this worker over to the retry
+// queue. Exhausted tasks are dead-lettered.
+func handleTransient(workerID string, task *models.Task, reason string) {
 	task.RetryCount++
+	task.Error = reason
 	// Check if we've exhausted all retry attempts
 	if task.RetryCount > maxRetries {
-		finalizeFailed(task, "exhausted retries")
+		finalizeFailed(workerID, task, "exhausted retries")
 		return
 	}
 
@@ -237,12 +1207,17 @@ func handleTransient(task *models.Task) {
 		log.Printf("Failed to store transient fail attempt: %v", err)
 	}
 	// Schedule the retry in Redis ZSET
-	if err := r.ScheduleRetry(task.ID, next); err != nil {
+	// On failure the task stays in-flight so the reaper requeues it once the lease expires
+	scheduled, err := r.ScheduleRetry(workerID, task.ID, next)
+	if err != nil {
 		log.Printf("Failed to schedule retry for task %s: %v", task.ID, err)
-		// Fallback: immediately re-enqueue to avoid losing the task
-		_ = r.ReenqueueByType(task.ID)
+		return
+	}
+	if !scheduled {
+		log.Printf("Lease on task %s was lost before scheduling its retry", task.ID)
+		return
 	}
 
-	log.Printf("Transient failure for task %s (type=%s, retry=%d, next_retry_at=%s)",
-		task.ID, task.JobType, task.RetryCount, next.Format(time.RFC3339))
+	log.Printf("Transient failure for task %s (type=%s, error=%s, retry=%d, next_retry_at=%s)",
+		task.ID, task.JobType, reason, task.RetryCount, next.Format(time.RFC3339))
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	r "github.com/yourusername/distributed-task-queue/src/redis"
+)
+
+func TestQueueSelectorOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		spec   string
+		strict bool
+		paused map[string]bool
+		rounds int
+		first  map[string]int // how often each queue is tried first
+	}{
+		{"weights", "critical=6,default=3,low=1", false, nil, 10,
+			map[string]int{"critical": 6, "default": 3, "low": 1}},
+		{"equal weights", "a,b", false, nil, 4,
+			map[string]int{"a": 2, "b": 2}},
+		{"paused queue", "critical=6,default=3,low=1", false, map[string]bool{"critical": true}, 8,
+			map[string]int{"default": 6, "low": 2}},
+		{"all but one paused", "a=5,b=1", false, map[string]bool{"a": true}, 3,
+			map[string]int{"b": 3}},
+		{"all paused", "a,b", false, map[string]bool{"a": true, "b": true}, 2,
+			map[string]int{}},
+		{"strict", "a=1,b=5", true, nil, 6,
+			map[string]int{"a": 6}},
+		{"strict with paused queue", "a=1,b=5,c", true, map[string]bool{"a": true}, 6,
+			map[string]int{"b": 6}},
+	} {
+		selector, err := parseQueues(tc.spec, tc.strict)
+		if err != nil {
+			t.Fatalf("%s: parseQueues(%q): %v", tc.name, tc.spec, err)
+		}
+		active := 0
+		for _, q := range selector.queues {
+			if !tc.paused[q.name] {
+				active++
+			}
+		}
+
+		first := make(map[string]int)
+		for round := 0; round < tc.rounds; round++ {
+			keys := selector.order(tc.paused)
+			// Every active queue is tried, paused ones never
+			if len(keys) != active {
+				t.Fatalf("%s: round %d tries %v, want %d queues", tc.name, round, keys, active)
+			}
+			for _, q := range selector.queues {
+				if tc.paused[q.name] {
+					for _, key := range keys {
+						if key == q.key {
+							t.Errorf("%s: round %d tries paused queue %q", tc.name, round, q.name)
+						}
+					}
+				}
+			}
+			if len(keys) > 0 {
+				for _, q := range selector.queues {
+					if q.key == keys[0] {
+						first[q.name]++
+					}
+				}
+			}
+		}
+
+		for name, want := range tc.first {
+			if first[name] != want {
+				t.Errorf("%s: %q tried first %d times in %d rounds, want %d", tc.name, name, first[name], tc.rounds, want)
+			}
+		}
+		if len(first) != len(tc.first) {
+			t.Errorf("%s: queues tried first %v, want %v", tc.name, first, tc.first)
+		}
+	}
+}
+
+func TestParseQueuesRejectsPriorityWithOthers(t *testing.T) {
+	if _, err := parseQueues(r.PRIORITY_QUEUE_NAME+",fifo", false); err == nil {
+		t.Errorf("%q combined with another queue was accepted", r.PRIORITY_QUEUE_NAME)
+	}
+}
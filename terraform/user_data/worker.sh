@@ -14,6 +14,7 @@ docker rm dtq-worker 2>/dev/null || true
 
 docker run -d --restart always \
   --name dtq-worker \
+  --stop-timeout 45 \
   -e REDIS_ADDR="${redis_private_ip}:6379" \
   ${worker_image} \
-  ./worker -queue=${queue_type} -mode=${mode}
\ No newline at end of file
+  ./worker -queues=${queue_type} -mode=${mode}
\ No newline at end of file
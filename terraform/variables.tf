@@ -58,7 +58,7 @@ variable "worker_desired_capacity" {
 }
 
 variable "worker_queue_type" {
-  description = "Queue type for workers (fifo or priority)"
+  description = "Queues workers consume, passed to -queues (e.g. fifo, priority or critical=6,default=3,low=1)"
   type        = string
   default     = "fifo"
 }